@@ -2,9 +2,16 @@ package api
 
 import (
 	"backend/game"
+	"backend/messages"
+	"backend/moderation"
 	"backend/room"
+	"backend/session"
+	"backend/words"
 	"encoding/json"
+	"errors"
+	"io"
 	"log"
+	"net"
 	"net/http"
 	"os"
 	"strings"
@@ -17,6 +24,7 @@ import (
 var upgrader = websocket.Upgrader{
 	ReadBufferSize:  1024,
 	WriteBufferSize: 1024,
+	Subprotocols:    []string{messages.DrawBinarySubprotocol},
 	CheckOrigin: func(r *http.Request) bool {
 		origin := r.Header.Get("Origin")
 		log.Printf("WebSocket CheckOrigin request from: %s", origin)
@@ -36,7 +44,7 @@ var upgrader = websocket.Upgrader{
 	},
 }
 
-func ServeWS(rm *room.RoomManager, w http.ResponseWriter, r *http.Request) {
+func ServeWS(rm *room.RoomManager, tokens *session.Signer, w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	roomId, ok := vars["roomId"]
 	if !ok {
@@ -52,9 +60,27 @@ func ServeWS(rm *room.RoomManager, w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	playerName := r.URL.Query().Get("playerName")
-	if playerName == "" {
-		w.WriteHeader(http.StatusBadRequest)
+	playerName, err := moderation.SanitiseName(r.URL.Query().Get("playerName"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	playerId := uuid.NewString()
+	resumed := false
+	if resumeToken := r.URL.Query().Get("resumeToken"); resumeToken != "" {
+		if resumedId, ok := tokens.Verify(roomId, resumeToken); ok {
+			playerId = resumedId
+			resumed = true
+		} else {
+			log.Printf("invalid resume token for room %s, joining as a new player", roomId)
+		}
+	}
+
+	// Players resuming their session have already got in once
+	if !resumed && !room.CheckPasscode(r.URL.Query().Get("passcode")) {
+		log.Printf("wrong passcode for room %s", roomId)
+		w.WriteHeader(http.StatusForbidden)
 		return
 	}
 
@@ -66,40 +92,114 @@ func ServeWS(rm *room.RoomManager, w http.ResponseWriter, r *http.Request) {
 	log.Println("Client connected via WebSocket from:", conn.RemoteAddr())
 
 	player := &game.Player{
-		Id:           uuid.NewString(),
-		Name:         playerName,
-		Conn:         conn,
-		Unregister:   room.Unregister,
-		Send:         make(chan []byte, 256),
-		GameMessages: room.Game.Messages,
+		Id:              playerId,
+		Name:            playerName,
+		ResumeToken:     tokens.Sign(roomId, playerId),
+		RemoteIP:        clientIP(r),
+		Conn:            conn,
+		ConnConfig:      room.Game.Config.Connection,
+		Unregister:      room.Unregister,
+		GameMessages:    room.Game.Messages,
+		JoinAsSpectator: r.URL.Query().Get("spectate") == "true",
+		BinaryDraw:      conn.Subprotocol() == messages.DrawBinarySubprotocol,
 	}
 
 	log.Printf("Registering new player connection to room %s: %s", roomId, player.Id)
-	room.Register <- player
-	room.PlayerReady <- player
+	if !room.Join(player) {
+		log.Printf("room %s closed before player %s could join", roomId, player.Id)
+		_ = conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseGoingAway, "room closed"))
+		_ = conn.Close()
+		return
+	}
 
 	go player.WritePump()
 	go player.ReadPump()
 }
 
+// clientIP works out where a request came from. Behind a proxy every request comes from the proxy, so
+// CLIENT_IP_HEADER can name a header the proxy sets with the real address (e.g. Fly-Client-IP).
+func clientIP(r *http.Request) string {
+	if header := os.Getenv("CLIENT_IP_HEADER"); header != "" {
+		if ip := strings.TrimSpace(r.Header.Get(header)); ip != "" {
+			return ip
+		}
+	}
+
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+type CreateRoomRequest struct {
+	WordPacks   []string `json:"wordPacks,omitempty"`
+	Passcode    string   `json:"passcode,omitempty"`
+	MaxPlayers  int      `json:"maxPlayers,omitempty"`
+	KnockToJoin bool     `json:"knockToJoin,omitempty"`
+}
+
 type CreateRoomResponse struct {
 	RoomId string `json:"roomId"`
 }
 
 func HandleCreateRoom(rm *room.RoomManager, w http.ResponseWriter, r *http.Request) {
-	room := rm.CreateRoom()
+	var req CreateRoomRequest
+	// An empty body is fine, the room just gets the defaults
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		log.Printf("invalid create room request: %s", err.Error())
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	newRoom, err := rm.CreateRoom(room.RoomOptions{
+		WordPacks:   req.WordPacks,
+		Passcode:    req.Passcode,
+		MaxPlayers:  req.MaxPlayers,
+		KnockToJoin: req.KnockToJoin,
+	})
+	if errors.Is(err, room.ErrTooManyRooms) || errors.Is(err, room.ErrNoFreeSlug) {
+		log.Printf("failed to create room: %s", err.Error())
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	} else if err != nil {
+		log.Printf("failed to create room: %s", err.Error())
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
 
 	res := CreateRoomResponse{
-		RoomId: room.Id,
+		RoomId: newRoom.Id,
 	}
 
 	w.WriteHeader(http.StatusOK)
-	err := json.NewEncoder(w).Encode(res)
+	err = json.NewEncoder(w).Encode(res)
 	if err != nil {
 		log.Printf("failed to respond to room creation: %s", err.Error())
 	}
 }
 
+type WordPacksResponse struct {
+	WordPacks []words.PackInfo `json:"wordPacks"`
+}
+
+func HandleGetWordPacks(rm *room.RoomManager, w http.ResponseWriter, r *http.Request) {
+	res := WordPacksResponse{
+		WordPacks: rm.WordPacks(),
+	}
+
+	w.WriteHeader(http.StatusOK)
+	err := json.NewEncoder(w).Encode(res)
+	if err != nil {
+		log.Printf("failed to respond with word packs: %s", err.Error())
+	}
+}
+
+type GetRoomResponse struct {
+	RoomId  string `json:"roomId"`
+	Private bool   `json:"private"` // A passcode is needed to join
+}
+
 func HandleGetRoom(rm *room.RoomManager, w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	roomId, ok := vars["roomId"]
@@ -116,8 +216,16 @@ func HandleGetRoom(rm *room.RoomManager, w http.ResponseWriter, r *http.Request)
 		return
 	}
 
+	res := GetRoomResponse{
+		RoomId:  room.Id,
+		Private: room.IsPrivate(),
+	}
+
 	w.WriteHeader(http.StatusOK)
-	return
+	err := json.NewEncoder(w).Encode(res)
+	if err != nil {
+		log.Printf("failed to respond with room %s: %s", roomId, err.Error())
+	}
 }
 
 func HandleIndex(staticDir string, fs http.Handler, w http.ResponseWriter, r *http.Request) {
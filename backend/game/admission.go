@@ -0,0 +1,133 @@
+package game
+
+import (
+	"backend/messages"
+	"encoding/json"
+	"log"
+	"slices"
+)
+
+// Admission controls who can join a room, as chosen by whoever created it
+type Admission struct {
+	MaxPlayers  int  // Most players and spectators in the room at once, 0 for no limit
+	KnockToJoin bool // New players wait for the host to let them in
+}
+
+// admit decides whether a new player can join straight away. It returns false if they've been turned away,
+// or are waiting for the host to let them in. Assumes the lock is held.
+func (g *Game) admit(player *Player) bool {
+	state := g.GameState
+
+	if state.isFull() {
+		log.Printf("GameState: Room is full, turning away player %s (%s).", player.Id, player.Name)
+		player.SendErrorWithCode(messages.ErrorCodeRoomFull, "This room is full.")
+		player.Disconnect(DisconnectRejected)
+		return false
+	}
+
+	// With nobody to ask, the first player in lets themselves in and becomes host
+	host := state.host()
+	if !state.admission.KnockToJoin || host == nil {
+		return true
+	}
+
+	state.pending = append(state.pending, player)
+	log.Printf("GameState: Player %s (%s) is waiting for the host to let them in.", player.Id, player.Name)
+	player.SendMessage(messages.JoinPendingResponse, nil)
+	host.SendMessage(messages.JoinRequestResponse, messages.JoinRequestPayload{PlayerID: player.Id, Name: player.Name})
+	return false
+}
+
+func (g *GameState) isFull() bool {
+	return g.admission.MaxPlayers > 0 && len(g.Players)+len(g.Spectators) >= g.admission.MaxPlayers
+}
+
+func (g *GameState) host() *Player {
+	if i := g.playerIndex(g.HostId); i >= 0 {
+		return g.Players[i]
+	}
+	return nil
+}
+
+func (g *GameState) pendingIndex(id string) int {
+	return slices.IndexFunc(g.pending, func(p *Player) bool { return p.Id == id })
+}
+
+// handleJoinResponse lets in or turns away a player waiting on the host, in any phase
+func (g *Game) handleJoinResponse(player *Player, msg messages.Message) {
+	state := g.GameState
+
+	if player.Id != state.HostId {
+		player.SendError("Only the host can let players in.")
+		return
+	}
+
+	var payload messages.RespondToJoinPayload
+	if err := json.Unmarshal(msg.Payload, &payload); err != nil {
+		player.SendError("Invalid join response format.")
+		return
+	}
+
+	i := state.pendingIndex(payload.PlayerID)
+	if i < 0 {
+		player.SendError("That player is no longer waiting to join.")
+		return
+	}
+	knocking := state.pending[i]
+	state.pending = slices.Delete(state.pending, i, i+1)
+
+	if !payload.Admit {
+		log.Printf("GameState: Host %s (%s) turned away player %s (%s).", player.Id, player.Name, knocking.Id, knocking.Name)
+		knocking.SendErrorWithCode(messages.ErrorCodeJoinDenied, "The host didn't let you in.")
+		knocking.Disconnect(DisconnectRejected)
+		return
+	}
+
+	if state.isFull() {
+		player.SendError("The room is full.")
+		knocking.SendErrorWithCode(messages.ErrorCodeRoomFull, "This room is full.")
+		knocking.Disconnect(DisconnectRejected)
+		return
+	}
+
+	log.Printf("GameState: Host %s (%s) let in player %s (%s).", player.Id, player.Name, knocking.Id, knocking.Name)
+	g.join(knocking)
+}
+
+// removePending forgets a waiting player who has gone away, letting the host know. Assumes the lock is held.
+func (g *GameState) removePending(player *Player) bool {
+	i := g.pendingIndex(player.Id)
+	if i < 0 || g.pending[i] != player {
+		return false
+	}
+
+	g.pending = slices.Delete(g.pending, i, i+1)
+	log.Printf("GameState: Player %s (%s) stopped waiting to join.", player.Id, player.Name)
+	if host := g.host(); host != nil {
+		host.SendMessage(messages.JoinRequestResponse, messages.JoinRequestPayload{PlayerID: player.Id, Name: player.Name, Withdrawn: true})
+	}
+	return true
+}
+
+// reviewPendingJoins passes the players waiting to join on to a new host, or lets them all in if there's
+// nobody left to ask. Assumes the lock is held.
+func (g *Game) reviewPendingJoins() {
+	state := g.GameState
+	if len(state.pending) == 0 {
+		return
+	}
+
+	host := state.host()
+	if host == nil {
+		waiting := state.pending
+		state.pending = nil
+		for _, p := range waiting {
+			g.join(p)
+		}
+		return
+	}
+
+	for _, p := range state.pending {
+		host.SendMessage(messages.JoinRequestResponse, messages.JoinRequestPayload{PlayerID: p.Id, Name: p.Name})
+	}
+}
@@ -0,0 +1,27 @@
+package game
+
+import "log"
+
+// AfkPolicy is what happens to players who don't respond to a phase change in time
+type AfkPolicy string
+
+const (
+	AfkPolicyMark   AfkPolicy = "mark"   // Keep them, but stop waiting on them until they're active again
+	AfkPolicyRemove AfkPolicy = "remove" // Disconnect them from the room
+)
+
+// setAfk marks a player as away or back. AFK players aren't waited on to ack phase changes or to guess.
+func (g *GameState) setAfk(player *Player, afk bool) {
+	if g.afk[player.Id] == afk {
+		return
+	}
+
+	if afk {
+		g.afk[player.Id] = true
+		g.BroadcastSystemMessage(player.Name + " is AFK.")
+	} else {
+		delete(g.afk, player.Id)
+		log.Printf("GameState: Player %s (%s) is no longer AFK.", player.Id, player.Name)
+	}
+	g.broadcastPlayerUpdate()
+}
@@ -0,0 +1,217 @@
+package game
+
+import (
+	"backend/messages"
+	"math"
+	"strconv"
+)
+
+// Rough memory cost of an operation before its points, used to keep each room's canvas under Config.MaxCanvasBytes
+const opOverheadBytes = 64
+
+const (
+	opStroke = "stroke"
+	opFill   = "fill"
+	opClear  = "clear"
+)
+
+// Canvas is the authoritative record of this turn's drawing. It's kept as an ordered list of operations
+// (strokes, fills and clears) that every client replays in the same order, so they all end up with the same
+// picture. Undo and redo move operations between that list and a redo stack.
+type Canvas struct {
+	ops       []*canvasOp
+	undone    []*canvasOp // Redo stack, most recently undone last
+	current   *canvasOp   // Stroke that has started and not yet ended, nil if it isn't being recorded
+	live      string      // ID of the stroke being drawn, which goes out to players even once recording stops
+	size      int         // Approximate bytes used by ops and undone
+	limit     int         // Operations stop being recorded once size would go over this
+	truncated bool        // Some of the drawing wasn't recorded because of the limit
+	nextId    int
+}
+
+type canvasOp struct {
+	id        string
+	kind      string
+	color     string
+	lineWidth float64
+	points    []float64 // Flattened x, y pairs, a fill has just the one
+	size      int
+}
+
+func NewCanvas(limit int) *Canvas {
+	return &Canvas{limit: limit}
+}
+
+// Reset wipes the canvas for a new turn
+func (c *Canvas) Reset() {
+	c.ops = nil
+	c.undone = nil
+	c.endStroke()
+	c.size = 0
+	c.truncated = false
+	c.nextId = 0
+}
+
+// Apply records a draw event from the drawer. It fills in the event's stroke ID if the client didn't give a
+// usable one, or with the operation affected for undo and redo. It returns false if the event changed nothing
+// and so doesn't need sending on. Events are still worth sending once the canvas is too full to record them,
+// it's only replay for players joining later that misses out.
+func (c *Canvas) Apply(event *messages.DrawEventPayload) bool {
+	switch event.EventType {
+	case messages.DrawEventStart:
+		c.current = c.push(c.newOp(event, opStroke))
+		c.live = event.StrokeID
+		c.addPoint(event.X, event.Y)
+	case messages.DrawEventDraw:
+		if c.live == "" {
+			return false
+		}
+		event.StrokeID = c.live
+		c.addPoint(event.X, event.Y)
+	case messages.DrawEventEnd:
+		if c.live == "" {
+			return false
+		}
+		event.StrokeID = c.live
+		c.endStroke()
+	case messages.DrawEventFill:
+		c.endStroke()
+		op := c.push(c.newOp(event, opFill))
+		if op != nil && c.reserve(16) {
+			op.points = append(op.points, event.X, event.Y)
+			op.size += 16
+		}
+	case messages.DrawEventClear:
+		c.endStroke()
+		c.push(c.newOp(event, opClear))
+	case messages.DrawEventUndo:
+		c.endStroke()
+		// Strokes since the limit was hit weren't recorded, so the last op we have isn't the one being undone
+		if len(c.ops) == 0 || c.truncated {
+			return false
+		}
+		op := c.ops[len(c.ops)-1]
+		c.ops = c.ops[:len(c.ops)-1]
+		c.undone = append(c.undone, op)
+		event.StrokeID = op.id
+	case messages.DrawEventRedo:
+		c.endStroke()
+		if len(c.undone) == 0 || c.truncated {
+			return false
+		}
+		op := c.undone[len(c.undone)-1]
+		c.undone = c.undone[:len(c.undone)-1]
+		c.ops = append(c.ops, op)
+		event.StrokeID = op.id
+	default:
+		return false
+	}
+
+	return true
+}
+
+func (c *Canvas) endStroke() {
+	c.current = nil
+	c.live = ""
+}
+
+// newOp creates an operation for the event, giving it a fresh ID if the client's is missing or already taken
+func (c *Canvas) newOp(event *messages.DrawEventPayload, kind string) *canvasOp {
+	for event.StrokeID == "" || c.findOp(event.StrokeID) != nil {
+		c.nextId++
+		event.StrokeID = "s" + strconv.Itoa(c.nextId)
+	}
+
+	return &canvasOp{
+		id:        event.StrokeID,
+		kind:      kind,
+		color:     event.Color,
+		lineWidth: event.LineWidth,
+		size:      opOverheadBytes + len(event.StrokeID) + len(event.Color),
+	}
+}
+
+// push adds a new operation, which like in any editor throws away anything that could have been redone.
+// Returns nil if the canvas is full.
+func (c *Canvas) push(op *canvasOp) *canvasOp {
+	for _, u := range c.undone {
+		c.size -= u.size
+	}
+	c.undone = nil
+
+	if !c.reserve(op.size) {
+		return nil
+	}
+	c.ops = append(c.ops, op)
+	return op
+}
+
+func (c *Canvas) addPoint(x, y float64) {
+	if c.current == nil || !c.reserve(16) {
+		return
+	}
+	c.current.points = append(c.current.points, x, y)
+	c.current.size += 16
+}
+
+// reserve accounts for n more bytes, returning false and stopping recording if that would go over the limit
+func (c *Canvas) reserve(n int) bool {
+	if c.truncated || c.size+n > c.limit {
+		c.truncated = true
+		c.current = nil
+		return false
+	}
+	c.size += n
+	return true
+}
+
+func (c *Canvas) findOp(id string) *canvasOp {
+	for _, op := range c.ops {
+		if op.id == id {
+			return op
+		}
+	}
+	for _, op := range c.undone {
+		if op.id == id {
+			return op
+		}
+	}
+	return nil
+}
+
+// IsTruncated reports whether the canvas has stopped recording, after which undo and redo are refused
+func (c *Canvas) IsTruncated() bool {
+	return c.truncated
+}
+
+func (c *Canvas) IsEmpty() bool {
+	return len(c.ops) == 0 && len(c.undone) == 0
+}
+
+// Snapshot is the compact form of the canvas sent to clients. Coordinates are rounded to a tenth of a pixel,
+// which is plenty to redraw with.
+func (c *Canvas) Snapshot() messages.CanvasSnapshotPayload {
+	return messages.CanvasSnapshotPayload{
+		Operations: snapshotOps(c.ops),
+		Undone:     snapshotOps(c.undone),
+		Truncated:  c.truncated,
+	}
+}
+
+func snapshotOps(ops []*canvasOp) []messages.CanvasOperation {
+	snapshot := make([]messages.CanvasOperation, 0, len(ops))
+	for _, op := range ops {
+		points := make([]float64, len(op.points))
+		for i, v := range op.points {
+			points[i] = math.Round(v*10) / 10
+		}
+		snapshot = append(snapshot, messages.CanvasOperation{
+			Type:      op.kind,
+			StrokeID:  op.id,
+			Color:     op.color,
+			LineWidth: op.lineWidth,
+			Points:    points,
+		})
+	}
+	return snapshot
+}
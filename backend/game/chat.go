@@ -0,0 +1,117 @@
+package game
+
+import (
+	"backend/messages"
+	"backend/moderation"
+	"backend/wordmatch"
+	"encoding/json"
+	"log"
+	"unicode/utf8"
+)
+
+const maxChatLength = 200 // In runes
+
+// chatText pulls the text out of a chat message, telling the sender if there's something wrong with it
+func chatText(player *Player, msg messages.Message) (string, bool) {
+	var payload messages.ChatMessagePayload
+	if err := json.Unmarshal(msg.Payload, &payload); err != nil {
+		player.SendError("Invalid chat format.")
+		return "", false
+	}
+
+	text := moderation.Sanitise(payload.Message)
+	if text == "" {
+		return "", false
+	}
+	if utf8.RuneCountInString(text) > maxChatLength {
+		player.SendError("Chat messages can be at most 200 characters.")
+		return "", false
+	}
+	return text, true
+}
+
+// handleChat sends a chat message to everyone. During a turn, chat from players goes through the phase
+// handler instead, so it can be kept away from anyone still guessing.
+func (g *Game) handleChat(player *Player, msg messages.Message) {
+	text, ok := chatText(player, msg)
+	if !ok {
+		return
+	}
+
+	if g.leaksWord(player, text) {
+		log.Printf("GameState: Blocked chat from %s (%s) that gives away the word.", player.Id, player.Name)
+		player.SendErrorWithCode(messages.ErrorCodeAnswerLeak, "Your message wasn't sent as it gives away the word.")
+		return
+	}
+	if text, ok = g.GameState.moderate(player, text); ok {
+		g.GameState.BroadcastChatMessage(player.Name, text)
+	}
+}
+
+// secretWords are the words players are meant to be guessing, if there are any. While the drawer is picking
+// it could be any of the choices they were offered. The drawer knows it from then, and it stays secret until
+// the turn's results are shown.
+func (g *Game) secretWords() []string {
+	handler := g.GameHandler
+	if ackHandler, ok := handler.(*PhaseChangeHandler); ok {
+		if next, ok := ackHandler.HandlerToChangeTo.(*RoundInProgressHandler); ok {
+			return []string{next.Word}
+		}
+		if _, ok := ackHandler.HandlerToChangeTo.(*RoundFinishedHandler); ok && g.GameState.Word != "" {
+			return []string{g.GameState.Word}
+		}
+		return nil
+	}
+
+	switch h := handler.(type) {
+	case *RoundSetupHandler:
+		if h.WordToPickFrom != nil {
+			return *h.WordToPickFrom
+		}
+	case *RoundInProgressHandler:
+		return []string{g.GameState.Word}
+	}
+	return nil
+}
+
+// leaksWord reports whether chat everyone will see gives away the word. Only the drawer and players who've
+// guessed it know it, so only their messages need checking.
+func (g *Game) leaksWord(player *Player, text string) bool {
+	state := g.GameState
+
+	if !state.knowsWord(player) {
+		return false
+	}
+	for _, word := range g.secretWords() {
+		if word != "" && wordmatch.NewMatcher(word, state.Words.Aliases(word)).Leaks(text) {
+			return true
+		}
+	}
+	return false
+}
+
+// knowsWord reports whether a player is allowed to know the word: the drawer and anyone who has guessed it
+func (g *GameState) knowsWord(player *Player) bool {
+	_, guessed := g.CorrectGuessTimes[player.Id]
+	return guessed || g.isDrawer(player)
+}
+
+// sendGuessedChat sends a chat message only to the players who know the word, so they can talk about it
+// without giving it away
+func (g *GameState) sendGuessedChat(sender *Player, message string) {
+	message, ok := g.moderate(sender, message)
+	if !ok {
+		return
+	}
+
+	recipients := make([]*Player, 0, len(g.Players))
+	for _, p := range g.Players {
+		if g.knowsWord(p) {
+			recipients = append(recipients, p)
+		}
+	}
+
+	payload := messages.ChatPayload{SenderName: sender.Name, Message: message, Channel: messages.ChatChannelGuessed}
+	msg := messages.Message{Type: messages.ChatResponse, Payload: json.RawMessage(messages.MustMarshal(payload))}
+	g.Broadcaster.BroadcastToPlayers(msg, recipients)
+}
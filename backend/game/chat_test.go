@@ -0,0 +1,82 @@
+package game
+
+import (
+	"backend/messages"
+	"backend/words"
+	"encoding/json"
+	"testing"
+)
+
+// recordingBroadcaster keeps every message broadcast to the whole room
+type recordingBroadcaster struct {
+	broadcasts []messages.Message
+}
+
+func (b *recordingBroadcaster) Broadcast(m messages.Message) {
+	b.broadcasts = append(b.broadcasts, m)
+}
+
+func (b *recordingBroadcaster) BroadcastToPlayers(message messages.Message, players []*Player) {}
+
+func (b *recordingBroadcaster) BroadcastDrawEvent(event messages.DrawEventPayload, players []*Player) {
+}
+
+// chats is the player chat broadcast so far, leaving out system messages
+func (b *recordingBroadcaster) chats(t *testing.T) []string {
+	t.Helper()
+
+	var chats []string
+	for _, m := range b.broadcasts {
+		if m.Type != messages.ChatResponse {
+			continue
+		}
+		var payload messages.ChatPayload
+		if err := json.Unmarshal(m.Payload, &payload); err != nil {
+			t.Fatalf("unmarshalling chat: %v", err)
+		}
+		if !payload.IsSystem {
+			chats = append(chats, payload.Message)
+		}
+	}
+	return chats
+}
+
+func chatMessage(text string) messages.Message {
+	return messages.Message{Type: messages.ClientChat, Payload: messages.MustMarshal(messages.ChatMessagePayload{Message: text})}
+}
+
+func TestDrawerChatDuringSetupCantGiveAwayChoices(t *testing.T) {
+	b := &recordingBroadcaster{}
+	deck := words.NewDeck(&words.Pack{Name: "test", Words: []string{"giraffe", "volcano", "umbrella"}})
+	g := NewGame(b, deck, DefaultConfig(), Admission{})
+	t.Cleanup(g.stop)
+
+	drawer, guesser := &Player{Id: "drawer", Name: "Drawer"}, &Player{Id: "guesser", Name: "Guesser"}
+	state := g.GameState
+	state.Players = []*Player{drawer, guesser}
+	state.IsActive = true
+	state.Settings.WordChoiceCount = 3
+
+	setup := &RoundSetupHandler{}
+	g.updateHandler(setup)
+	if !state.isDrawer(drawer) || setup.WordToPickFrom == nil {
+		t.Fatalf("drawer wasn't offered any words")
+	}
+
+	for _, choice := range *setup.WordToPickFrom {
+		g.handleChat(drawer, chatMessage("it's "+choice+" probably"))
+	}
+	if chats := b.chats(t); len(chats) != 0 {
+		t.Fatalf("drawer's chat giving away the choices was sent: %q", chats)
+	}
+
+	g.handleChat(drawer, chatMessage("picking now"))
+	// The guesser hasn't seen the choices, so can say what they like
+	g.handleChat(guesser, chatMessage("is it a giraffe"))
+
+	want := []string{"picking now", "is it a giraffe"}
+	chats := b.chats(t)
+	if len(chats) != len(want) || chats[0] != want[0] || chats[1] != want[1] {
+		t.Fatalf("got chat %q, want %q", chats, want)
+	}
+}
@@ -0,0 +1,92 @@
+package game
+
+import (
+	"errors"
+	"net"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// ConnConfig controls how a player's connection is kept alive and how much it may send
+type ConnConfig struct {
+	PingInterval   time.Duration // How often the server pings the client
+	PongWait       time.Duration // How long the client can go without answering before it's presumed gone, more than PingInterval
+	WriteWait      time.Duration // How long a single write may take
+	MaxMessageSize int64         // Largest message accepted from the client, in bytes
+	RateLimits     RateLimits    // How fast the client may send each kind of message
+}
+
+func DefaultConnConfig() ConnConfig {
+	return ConnConfig{
+		PingInterval:   25 * time.Second,
+		PongWait:       60 * time.Second,
+		WriteWait:      10 * time.Second,
+		MaxMessageSize: 32 * 1024,
+		RateLimits:     DefaultRateLimits(),
+	}
+}
+
+// DisconnectReason is why a player's connection ended, so the game can tell someone leaving from a dropped connection
+type DisconnectReason int32
+
+const (
+	DisconnectUnknown      DisconnectReason = iota
+	DisconnectLeft                          // The client closed the connection on purpose
+	DisconnectGoingAway                     // The page was refreshed or navigated away from
+	DisconnectTimeout                       // The client stopped answering pings
+	DisconnectError                         // The connection failed or the client broke protocol
+	DisconnectTooSlow                       // The client couldn't keep up with the messages sent to it
+	DisconnectUnresponsive                  // The server gave up waiting for the client to respond
+	DisconnectRejected                      // The client wasn't allowed into the room
+	DisconnectKicked                        // The host removed the player from the room
+	DisconnectFlooding                      // The client kept sending messages faster than the rate limits allow
+)
+
+var disconnectReasonName = map[DisconnectReason]string{
+	DisconnectUnknown:      "Unknown",
+	DisconnectLeft:         "Left",
+	DisconnectGoingAway:    "GoingAway",
+	DisconnectTimeout:      "Timeout",
+	DisconnectError:        "Error",
+	DisconnectTooSlow:      "TooSlow",
+	DisconnectUnresponsive: "Unresponsive",
+	DisconnectRejected:     "Rejected",
+	DisconnectKicked:       "Kicked",
+	DisconnectFlooding:     "Flooding",
+}
+
+func (r DisconnectReason) String() string {
+	return disconnectReasonName[r]
+}
+
+// Disconnect closes the player's connection from the server side, recording why
+func (p *Player) Disconnect(reason DisconnectReason) {
+	p.setDisconnectReason(reason)
+	p.CloseSend()
+}
+
+// disconnectReasonFor works out why reading from the connection failed
+func disconnectReasonFor(err error) DisconnectReason {
+	var netErr net.Error
+	switch {
+	case websocket.IsCloseError(err, websocket.CloseNormalClosure):
+		return DisconnectLeft
+	case websocket.IsCloseError(err, websocket.CloseGoingAway):
+		return DisconnectGoingAway
+	case errors.As(err, &netErr) && netErr.Timeout():
+		return DisconnectTimeout
+	default:
+		return DisconnectError
+	}
+}
+
+// setDisconnectReason records why the player is disconnecting. Only the first reason sticks, as later
+// errors are usually just fallout from the first.
+func (p *Player) setDisconnectReason(reason DisconnectReason) {
+	p.disconnectReason.CompareAndSwap(int32(DisconnectUnknown), int32(reason))
+}
+
+func (p *Player) DisconnectReason() DisconnectReason {
+	return DisconnectReason(p.disconnectReason.Load())
+}
@@ -0,0 +1,78 @@
+package game
+
+import (
+	"backend/messages"
+	"backend/moderation"
+	"backend/wordmatch"
+	"fmt"
+	"log"
+	"strings"
+	"unicode/utf8"
+)
+
+// moderate applies the room's moderation action to text a player wants others to see. It returns the text
+// to send, or false if it shouldn't be sent at all.
+func (g *GameState) moderate(player *Player, text string) (string, bool) {
+	if g.blocklist == nil || !g.blocklist.Contains(text) {
+		return text, true
+	}
+
+	log.Printf("GameState: Message from %s (%s) matched the blocklist, action: %s.", player.Id, player.Name, g.Settings.ModerationAction)
+	switch g.Settings.ModerationAction {
+	case moderation.ActionReject:
+		player.SendErrorWithCode(messages.ErrorCodeModerated, "Your message wasn't sent as it contains blocked words.")
+		return "", false
+	case moderation.ActionWarn:
+		player.SendSystemMessage("Please keep it friendly.")
+		return text, true
+	default:
+		return g.blocklist.Mask(text), true
+	}
+}
+
+// moderateName applies the room's moderation action to a new player's name and makes sure it doesn't clash
+// with anyone else's. It returns false if they've been turned away.
+func (g *GameState) moderateName(player *Player) bool {
+	if g.blocklist != nil && g.blocklist.Contains(player.Name) {
+		log.Printf("GameState: Name of player %s (%s) matched the blocklist, action: %s.", player.Id, player.Name, g.Settings.ModerationAction)
+		switch g.Settings.ModerationAction {
+		case moderation.ActionReject:
+			player.SendErrorWithCode(messages.ErrorCodeInvalidName, "That name isn't allowed here.")
+			player.Disconnect(DisconnectRejected)
+			return false
+		case moderation.ActionWarn:
+			player.SendSystemMessage("Please pick a friendlier name next time.")
+		default:
+			player.Name = g.blocklist.Mask(player.Name)
+		}
+	}
+
+	player.Name = g.uniqueName(player.Name)
+	return true
+}
+
+// uniqueName numbers a name if someone in the room already goes by it, so nobody can pass themselves off as
+// someone else. Names that only differ by case, accents or punctuation count as the same.
+func (g *GameState) uniqueName(name string) string {
+	taken := make(map[string]bool)
+	for _, group := range [][]*Player{g.Players, g.Spectators, g.pending} {
+		for _, p := range group {
+			taken[nameKey(p.Name)] = true
+		}
+	}
+
+	candidate := name
+	for n := 2; taken[nameKey(candidate)]; n++ {
+		suffix := fmt.Sprintf(" %d", n)
+		base := []rune(name)
+		if limit := moderation.MaxNameLength - utf8.RuneCountInString(suffix); len(base) > limit {
+			base = base[:limit]
+		}
+		candidate = strings.TrimSpace(string(base)) + suffix
+	}
+	return candidate
+}
+
+func nameKey(name string) string {
+	return strings.ReplaceAll(wordmatch.Normalise(name), " ", "")
+}
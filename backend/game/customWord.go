@@ -0,0 +1,41 @@
+package game
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+const (
+	minCustomWordLength = 2
+	maxCustomWordLength = 30
+)
+
+// validateCustomWord checks a word the drawer came up with is something guessers can reasonably type,
+// returning it with surrounding and repeated whitespace removed
+func validateCustomWord(word string) (string, error) {
+	word = strings.Join(strings.Fields(word), " ")
+
+	length := utf8.RuneCountInString(word)
+	if length < minCustomWordLength || length > maxCustomWordLength {
+		return "", fmt.Errorf("must be between %d and %d characters", minCustomWordLength, maxCustomWordLength)
+	}
+
+	hasLetter := false
+	for _, r := range word {
+		switch {
+		case unicode.IsLetter(r):
+			hasLetter = true
+		case unicode.IsDigit(r), r == ' ', r == '-', r == '\'':
+		default:
+			return "", fmt.Errorf("can only contain letters, numbers, spaces, hyphens and apostrophes")
+		}
+	}
+	if !hasLetter {
+		return "", errors.New("must contain at least one letter")
+	}
+
+	return word, nil
+}
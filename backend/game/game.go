@@ -2,7 +2,12 @@ package game
 
 import (
 	"backend/messages"
+	"backend/moderation"
+	"backend/words"
+	"context"
+	"fmt"
 	"log"
+	"runtime/debug"
 	"slices"
 	"time"
 )
@@ -16,9 +21,35 @@ type Game struct {
 	GameHandler GamePhaseHandler
 	GameState   *GameState
 	Messages    chan GameMessage
+	Config      Config
+
+	wake chan struct{} // Tells HandleEvents the phase changed outside of it, so it picks up the new timeout
+}
+
+// Config is game behaviour set by the server rather than the host
+type Config struct {
+	ReconnectGracePeriod time.Duration         // How long a disconnected player keeps their place, 0 removes them straight away
+	MaxCanvasBytes       int                   // Cap on the memory used recording each turn's drawing for replay
+	AckTimeout           time.Duration         // How long players get to ack a phase change before the game moves on without them
+	VoteDuration         time.Duration         // How long a vote to kick or skip stays open
+	VoteCooldown         time.Duration         // How long a player has to wait after starting a vote to start another
+	Blocklist            *moderation.Blocklist // Words moderated in names, chat and custom words, nil for none
+	Connection           ConnConfig
 }
 
-func (g *Game) HandleEvents() {
+func DefaultConfig() Config {
+	return Config{
+		ReconnectGracePeriod: 30 * time.Second,
+		MaxCanvasBytes:       512 * 1024,
+		AckTimeout:           10 * time.Second,
+		VoteDuration:         30 * time.Second,
+		VoteCooldown:         60 * time.Second,
+		Connection:           DefaultConnConfig(),
+	}
+}
+
+// HandleEvents runs the game until ctx is cancelled
+func (g *Game) HandleEvents(ctx context.Context) {
 	for {
 		var timerChan <-chan time.Time
 		g.GameState.mu.Lock()
@@ -29,19 +60,43 @@ func (g *Game) HandleEvents() {
 
 		var newHandler GamePhaseHandler
 		select {
+		case <-ctx.Done():
+			g.stop()
+			return
+
+		case <-g.wake:
+			continue
+
 		case msg := <-g.Messages:
 			g.GameState.mu.Lock()
-			newHandler = g.GameHandler.HandleMessage(g.GameState, msg.player, msg.msg)
+			if g.GameState.afk[msg.player.Id] {
+				g.GameState.setAfk(msg.player, false)
+			}
+
+			newHandler = g.recoverHandler(fmt.Sprintf("%s message from %s: %s", msg.msg.Type, msg.player.Id, msg.msg.Payload), func() GamePhaseHandler {
+				if g.handleRoomMessage(msg.player, msg.msg) {
+					return g.GameHandler
+				}
+				if g.GameState.isSpectator(msg.player) {
+					g.handleSpectatorMessage(msg.player, msg.msg)
+					return g.GameHandler
+				}
+				return g.GameHandler.HandleMessage(g.GameState, msg.player, msg.msg)
+			})
 
 		case <-timerChan:
 			g.GameState.mu.Lock()
 
-			if g.GameState.timerForTimeout == nil {
-				// We've had an update to the store that means we no longer want to respect the timeout, ignore
+			if g.GameState.timerForTimeout == nil || g.GameState.timerForTimeout.C != timerChan {
+				// The phase changed outside the loop while we were waiting, so this timeout belongs to a phase
+				// that has already gone. Ignore it.
+				g.GameState.mu.Unlock()
 				continue
 			}
 
-			newHandler = g.GameHandler.HandleTimeOut(g.GameState)
+			newHandler = g.recoverHandler("timeout", func() GamePhaseHandler {
+				return g.GameHandler.HandleTimeOut(g.GameState)
+			})
 		}
 
 		g.updateHandler(newHandler)
@@ -49,8 +104,90 @@ func (g *Game) HandleEvents() {
 	}
 }
 
+// handleRoomMessage deals with messages about who is in the room rather than the game itself, which work the
+// same in every phase. It returns false if the message should go on to the phase handler.
+func (g *Game) handleRoomMessage(player *Player, msg messages.Message) bool {
+	state := g.GameState
+
+	if state.pendingIndex(player.Id) >= 0 {
+		player.SendError("Waiting for the host to let you in.")
+		return true
+	}
+	if state.playerIndex(player.Id) < 0 && !state.isSpectator(player) {
+		// Kicked, or otherwise removed with messages still on the way
+		log.Printf("GameState: Ignoring message type %s from %s (%s), who isn't in the room.", msg.Type, player.Id, player.Name)
+		return true
+	}
+
+	switch msg.Type {
+	case messages.ClientRespondToJoin:
+		g.handleJoinResponse(player, msg)
+	case messages.ClientKickPlayer:
+		g.handleKick(player, msg)
+	case messages.ClientBanPlayer:
+		g.handleBan(player, msg)
+	case messages.ClientTransferHost:
+		g.handleTransferHost(player, msg)
+	case messages.ClientStartVote:
+		g.handleStartVote(player, msg)
+	case messages.ClientCastVote:
+		g.handleCastVote(player, msg)
+	case messages.ClientChat:
+		if _, inTurn := g.GameHandler.(*RoundInProgressHandler); inTurn && !state.isSpectator(player) {
+			return false
+		}
+		g.handleChat(player, msg)
+	default:
+		return false
+	}
+	return true
+}
+
+// stop cancels the game's timers so nothing fires once it's no longer running
+func (g *Game) stop() {
+	state := g.GameState
+	state.mu.Lock()
+	defer state.mu.Unlock()
+
+	if state.timerForTimeout != nil {
+		state.timerForTimeout.Stop()
+		state.timerForTimeout = nil
+	}
+	for id, timer := range state.disconnected {
+		timer.Stop()
+		delete(state.disconnected, id)
+	}
+	if state.vote != nil {
+		state.vote.timer.Stop()
+		state.vote = nil
+	}
+	log.Printf("GameState: Stopped.")
+}
+
+// recoverHandler runs a call into the current phase handler, moving the room into the error phase if it
+// panics rather than letting it take down the game loop. cause describes what was being handled, for the logs.
+func (g *Game) recoverHandler(cause string, call func() GamePhaseHandler) (newHandler GamePhaseHandler) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("GameState Error: %s handler panicked handling %s: %v\n%s", g.GameHandler.Phase(), cause, r, debug.Stack())
+			newHandler = &ErrorHandler{Reason: fmt.Sprintf("%s handler panicked: %v", g.GameHandler.Phase(), r)}
+		}
+	}()
+
+	return call()
+}
+
+// recoverEvent runs something that happens to the game outside the game loop, like a timer firing, moving the
+// room into the error phase if it panics. Assumes the lock is held.
+func (g *Game) recoverEvent(cause string, call func()) {
+	g.updateHandler(g.recoverHandler(cause, func() GamePhaseHandler {
+		call()
+		return g.GameHandler
+	}))
+}
+
 func (g *Game) updateHandler(newHandler GamePhaseHandler) {
-	if newHandler.Phase() == g.GameHandler.Phase() {
+	if newHandler == g.GameHandler {
 		return
 	}
 
@@ -60,10 +197,32 @@ func (g *Game) updateHandler(newHandler GamePhaseHandler) {
 	}
 
 	g.GameHandler = newHandler
-	g.GameHandler.StartPhase(g.GameState)
+	started := g.recoverHandler("start of phase", func() GamePhaseHandler {
+		g.GameHandler.StartPhase(g.GameState)
+		return g.GameHandler
+	})
+	if started != newHandler {
+		// Starting the phase failed, drop anything it set up before going into the error phase
+		if g.GameState.timerForTimeout != nil {
+			g.GameState.timerForTimeout.Stop()
+			g.GameState.timerForTimeout = nil
+		}
+		g.GameHandler = started
+		g.GameHandler.StartPhase(g.GameState)
+	}
+
+	// A vote to skip a turn that has just ended is moot
+	if v := g.GameState.vote; v != nil && v.kind == VoteSkip {
+		g.tallyVote()
+	}
+
+	select {
+	case g.wake <- struct{}{}:
+	default:
+	}
 }
 
-func NewGame(b Broadcaster) *Game {
+func NewGame(b Broadcaster, deck *words.Deck, config Config, admission Admission) *Game {
 	handler := GamePhaseHandler(&WaitingInLobbyHandler{})
 
 	return &Game{
@@ -75,12 +234,24 @@ func NewGame(b Broadcaster) *Game {
 			Broadcaster:                  b,
 			IsActive:                     false,
 			timerForTimeout:              nil,
-			TotalRounds:                  1, // Default to 1 round (each player draws once)
+			Settings:                     DefaultGameSettings(),
 			CurrentRound:                 0,
 			PlayersWhoHaveDrawnThisRound: make([]string, 0),
+			Words:                        deck,
+			Canvas:                       NewCanvas(config.MaxCanvasBytes),
+			disconnected:                 make(map[string]*time.Timer),
+			afk:                          make(map[string]bool),
+			ackTimeout:                   config.AckTimeout,
+			admission:                    admission,
+			bannedIds:                    make(map[string]bool),
+			bannedIPs:                    make(map[string]bool),
+			voteCooldowns:                make(map[string]time.Time),
+			blocklist:                    config.Blocklist,
 		},
 		GameHandler: handler,
 		Messages:    make(chan GameMessage, 5),
+		Config:      config,
+		wake:        make(chan struct{}, 1),
 	}
 }
 
@@ -90,33 +261,185 @@ func (g *Game) AddPlayer(player *Player) {
 	state.mu.Lock()
 	defer state.mu.Unlock()
 
-	// Avoid adding duplicates
-	for _, p := range state.Players {
-		if p.Id == player.Id {
+	if state.isBanned(player) {
+		log.Printf("GameState: Turning away banned player %s (%s).", player.Id, player.Name)
+		player.SendErrorWithCode(messages.ErrorCodeBanned, "You've been banned from this room.")
+		player.Disconnect(DisconnectRejected)
+		return
+	}
+
+	// Avoid adding duplicates, but a player reconnecting with their resume token takes back their old place
+	if i := state.playerIndex(player.Id); i >= 0 {
+		if existing := state.Players[i]; existing != player {
+			g.resumePlayer(i, player)
+		} else {
 			log.Printf("GameState: Player %s (%s) already marked as ready.", player.Id, player.Name)
-			g.sendGameInfo(player)
-			return
 		}
+		g.sendGameInfo(player)
+		g.requestAck(player)
+		return
+	}
+
+	if i := state.spectatorIndex(player.Id); i >= 0 {
+		// As with resumePlayer, they keep the name they were let in with rather than whatever they reconnected with
+		player.Name = state.Spectators[i].Name
+		player.waitingToPlay = state.Spectators[i].waitingToPlay
+		player.admitted.Store(true)
+		state.Spectators[i] = player
+		log.Printf("GameState: Spectator %s (%s) reconnected.", player.Id, player.Name)
+		g.sendGameInfo(player)
+		return
+	}
+
+	if state.moderateName(player) && g.admit(player) {
+		g.join(player)
+	}
+}
+
+// join adds a newly admitted player to the game. Assumes the lock is held.
+func (g *Game) join(player *Player) {
+	state := g.GameState
+	player.admitted.Store(true)
+
+	// Joining mid-game would shift the drawing order and the count of who needs to guess, so they watch until
+	// the next round starts
+	if player.JoinAsSpectator || state.IsActive {
+		state.addSpectator(player, !player.JoinAsSpectator)
+		g.sendGameInfo(player)
+		state.broadcastPlayerUpdate()
+		return
 	}
 
 	state.Players = append(state.Players, player)
 	log.Printf("GameState: Player %s (%s) marked ready. Total ready players: %d", player.Id, player.Name, len(state.Players))
 
 	// Assign host to the first player
-	if len(state.Players) == 1 {
+	if state.HostId == "" {
 		state.HostId = player.Id
 		log.Printf("GameState: Player %s (%s) assigned as Host.", player.Id, player.Name)
 	}
 
 	g.sendGameInfo(player)
+	g.requestAck(player)
 	state.broadcastPlayerUpdate()
 }
 
-func (g *Game) RemovePlayer(player *Player) {
+// resumePlayer swaps a reconnected player's new connection in for their old one. Everything else about
+// them (score, drawing, guesses, host) is keyed by ID or slot so carries over untouched.
+func (g *Game) resumePlayer(idx int, player *Player) {
+	state := g.GameState
+	existing := state.Players[idx]
+
+	if timer, ok := state.disconnected[player.Id]; ok {
+		timer.Stop()
+		delete(state.disconnected, player.Id)
+	}
+
+	player.Name = existing.Name
+	player.Score = existing.Score
+	player.admitted.Store(true)
+	state.Players[idx] = player
+
+	log.Printf("GameState: Player %s (%s) resumed their session.", player.Id, player.Name)
+	state.broadcastPlayerUpdate()
+}
+
+// checkAcks moves on to the next phase if we were only waiting on acks from players who have gone. Assumes
+// the lock is held.
+func (g *Game) checkAcks() {
+	if ackHandler, ok := g.GameHandler.(*PhaseChangeHandler); ok && ackHandler.allAcked(g.GameState) {
+		g.updateHandler(ackHandler.HandlerToChangeTo)
+	}
+}
+
+// requestAck asks a player to ack the phase change if we're waiting on one, as they'll have missed the
+// original request if they joined or reconnected since it was sent
+func (g *Game) requestAck(player *Player) {
+	if ackHandler, ok := g.GameHandler.(*PhaseChangeHandler); ok {
+		player.SendMessage(messages.PhaseChangeAckResponse, ackHandler.ackPayload())
+	}
+}
+
+// DisconnectPlayer is called when a player's connection goes away. Unless they chose to leave, they stay in
+// the game for the reconnect grace period and are only removed if they haven't resumed their session by then.
+func (g *Game) DisconnectPlayer(player *Player) {
 	state := g.GameState
 	state.mu.Lock()
 	defer state.mu.Unlock()
 
+	if state.removePending(player) {
+		return
+	}
+
+	if i := state.spectatorIndex(player.Id); i >= 0 {
+		// Spectators have nothing worth holding on to
+		if state.Spectators[i] == player {
+			state.removeSpectator(player)
+			state.broadcastPlayerUpdate()
+		}
+		return
+	}
+
+	i := state.playerIndex(player.Id)
+	if i < 0 || state.Players[i] != player {
+		// Never made it into the game, or has already reconnected on a new connection
+		return
+	}
+
+	reason := player.DisconnectReason()
+	if reason == DisconnectLeft || reason == DisconnectUnresponsive || reason == DisconnectFlooding || g.Config.ReconnectGracePeriod <= 0 {
+		log.Printf("GameState: Player %s (%s) disconnected (%s), removing.", player.Id, player.Name, reason)
+		g.removePlayer(player)
+		return
+	}
+
+	log.Printf("GameState: Player %s (%s) disconnected (%s), holding their place for %s.", player.Id, player.Name, reason, g.Config.ReconnectGracePeriod)
+	if reason == DisconnectTimeout || reason == DisconnectTooSlow {
+		state.BroadcastSystemMessage(player.Name + " lost connection.")
+	}
+	var timer *time.Timer
+	timer = time.AfterFunc(g.Config.ReconnectGracePeriod, func() {
+		state.mu.Lock()
+		defer state.mu.Unlock()
+
+		// Only remove them if this is still the disconnect we were waiting on
+		if state.disconnected[player.Id] != timer {
+			return
+		}
+		delete(state.disconnected, player.Id)
+		log.Printf("GameState: Player %s (%s) didn't reconnect in time.", player.Id, player.Name)
+		g.recoverEvent("reconnect grace period ending for "+player.Id, func() {
+			g.removePlayer(player)
+		})
+	})
+	state.disconnected[player.Id] = timer
+	g.checkAcks()
+
+	state.broadcastPlayerUpdate()
+}
+
+func (g *Game) RemovePlayer(player *Player) {
+	g.GameState.mu.Lock()
+	defer g.GameState.mu.Unlock()
+
+	g.removePlayer(player)
+}
+
+// removePlayer takes a player out of the game, assumes the lock is held
+func (g *Game) removePlayer(player *Player) {
+	state := g.GameState
+
+	if state.isSpectator(player) {
+		state.removeSpectator(player)
+		state.broadcastPlayerUpdate()
+		return
+	}
+
+	if timer, ok := state.disconnected[player.Id]; ok {
+		timer.Stop()
+		delete(state.disconnected, player.Id)
+	}
+
 	found := false
 	playerIndex := -1
 	for i, p := range state.Players {
@@ -136,6 +459,7 @@ func (g *Game) RemovePlayer(player *Player) {
 	log.Printf("GameState: Player %s (%s) removed. Remaining players: %d", player.Id, player.Name, len(state.Players))
 
 	delete(g.GameState.CorrectGuessTimes, player.Id)
+	delete(g.GameState.afk, player.Id)
 
 	wasHost := state.HostId == player.Id
 
@@ -146,11 +470,12 @@ func (g *Game) RemovePlayer(player *Player) {
 		} else {
 			state.HostId = ""
 		}
+		g.reviewPendingJoins()
 	}
 
 	state.broadcastPlayerUpdate()
 
-	wasDrawer := state.IsActive && state.CurrentDrawerIdx == playerIndex
+	wasDrawer := state.IsActive && g.turnInPlay() && state.CurrentDrawerIdx == playerIndex
 	if len(state.Players) < minPlayersToStart {
 		g.updateHandler(ackPhaseTransitionTo(&GameOverHandler{}))
 	} else {
@@ -165,7 +490,25 @@ func (g *Game) RemovePlayer(player *Player) {
 
 		if wasDrawer || allGuessed {
 			log.Printf("GameState: Ending turn early due to player %s leaving (was drawer: %t, all guessed now: %t).", player.Name, wasDrawer, allGuessed)
-			g.updateHandler(ackPhaseTransitionTo(&RoundFinishedHandler{}))
+			g.updateHandler(ackPhaseTransitionTo(&RoundFinishedHandler{DrawerLeft: wasDrawer}))
 		}
 	}
+
+	g.checkAcks()
+
+	// They may have been what a vote was about, or one of the votes it was waiting on
+	g.tallyVote()
+}
+
+// turnInPlay reports whether the current drawer has been picked and their turn hasn't finished yet
+func (g *Game) turnInPlay() bool {
+	switch h := g.GameHandler.(type) {
+	case *RoundSetupHandler, *RoundInProgressHandler:
+		return true
+	case *PhaseChangeHandler:
+		_, inProgress := h.HandlerToChangeTo.(*RoundInProgressHandler)
+		return inProgress
+	default:
+		return false
+	}
 }
@@ -0,0 +1,114 @@
+package game
+
+import (
+	"backend/messages"
+	"encoding/json"
+	"log"
+	"math/rand"
+	"time"
+)
+
+// Points in the turn, as a fraction of the turn duration, at which a letter is revealed to guessers
+var hintFractions = []float64{0.5, 0.75}
+
+type hint struct {
+	index      int // rune index into the word
+	letter     string
+	revealedAt time.Time
+}
+
+// hintTimes works out when hints should be revealed for the current word. Short words get fewer hints
+// so that at least half of the letters are always left to guess.
+func hintTimes(word string, turnStart time.Time, turnDuration time.Duration) []time.Time {
+	maxHints := (len(hintableIndexes(word)) - 1) / 2
+	if maxHints > len(hintFractions) {
+		maxHints = len(hintFractions)
+	}
+
+	times := make([]time.Time, 0, maxHints)
+	for _, fraction := range hintFractions[:max(maxHints, 0)] {
+		times = append(times, turnStart.Add(time.Duration(float64(turnDuration)*fraction)))
+	}
+	return times
+}
+
+// hintableIndexes returns the rune indexes of the word that could be given away as a hint
+func hintableIndexes(word string) []int {
+	indexes := make([]int, 0, len(word))
+	for i, r := range []rune(word) {
+		if isGuessableRune(r) {
+			indexes = append(indexes, i)
+		}
+	}
+	return indexes
+}
+
+// revealHint picks a random letter that hasn't been revealed yet and sends it to everyone still guessing
+func (g *GameState) revealHint() {
+	candidates := make([]int, 0)
+	for _, i := range hintableIndexes(g.Word) {
+		if !g.isHintRevealed(i) {
+			candidates = append(candidates, i)
+		}
+	}
+	if len(candidates) == 0 {
+		return
+	}
+
+	index := candidates[rand.Intn(len(candidates))]
+	g.Hints = append(g.Hints, hint{
+		index:      index,
+		letter:     string([]rune(g.Word)[index]),
+		revealedAt: time.Now(),
+	})
+
+	guessers := g.playersStillGuessing()
+	log.Printf("GameState: Revealing hint %d to %d guessers", len(g.Hints), len(guessers))
+
+	payload := messages.TurnHelpPayload{RevealedLetters: g.revealedLetters()}
+	msg := messages.Message{Type: messages.TurnHelpResponse, Payload: json.RawMessage(messages.MustMarshal(payload))}
+	g.Broadcaster.BroadcastToPlayers(msg, guessers)
+}
+
+func (g *GameState) isHintRevealed(index int) bool {
+	for _, h := range g.Hints {
+		if h.index == index {
+			return true
+		}
+	}
+	return false
+}
+
+func (g *GameState) revealedLetters() []messages.RevealedLetter {
+	letters := make([]messages.RevealedLetter, 0, len(g.Hints))
+	for _, h := range g.Hints {
+		letters = append(letters, messages.RevealedLetter{Index: h.index, Letter: h.letter})
+	}
+	return letters
+}
+
+// hintsRevealedBy counts the hints that had been given out at the time t
+func (g *GameState) hintsRevealedBy(t time.Time) int {
+	count := 0
+	for _, h := range g.Hints {
+		if !h.revealedAt.After(t) {
+			count++
+		}
+	}
+	return count
+}
+
+// playersStillGuessing is everyone except the drawer and those who have already guessed the word
+func (g *GameState) playersStillGuessing() []*Player {
+	players := make([]*Player, 0, len(g.Players))
+	for i, p := range g.Players {
+		if i == g.CurrentDrawerIdx {
+			continue
+		}
+		if _, guessed := g.CorrectGuessTimes[p.Id]; guessed {
+			continue
+		}
+		players = append(players, p)
+	}
+	return players
+}
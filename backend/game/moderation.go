@@ -0,0 +1,124 @@
+package game
+
+import (
+	"backend/messages"
+	"encoding/json"
+	"log"
+)
+
+// hostTarget checks the sender is the host and finds the player, spectator or player waiting to join they're
+// acting on. It returns nil, having told the sender why, if the action isn't allowed.
+func (g *Game) hostTarget(sender *Player, targetId string, action string) *Player {
+	state := g.GameState
+
+	if sender.Id != state.HostId {
+		sender.SendError("Only the host can " + action + " players.")
+		return nil
+	}
+	if targetId == sender.Id {
+		sender.SendError("You can't " + action + " yourself.")
+		return nil
+	}
+
+	if i := state.playerIndex(targetId); i >= 0 {
+		return state.Players[i]
+	}
+	if i := state.spectatorIndex(targetId); i >= 0 {
+		return state.Spectators[i]
+	}
+	if i := state.pendingIndex(targetId); i >= 0 {
+		return state.pending[i]
+	}
+
+	sender.SendError("That player isn't in the room.")
+	return nil
+}
+
+func (g *Game) handleKick(sender *Player, msg messages.Message) {
+	var payload messages.TargetPlayerPayload
+	if err := json.Unmarshal(msg.Payload, &payload); err != nil {
+		sender.SendError("Invalid kick format.")
+		return
+	}
+
+	target := g.hostTarget(sender, payload.PlayerID, "kick")
+	if target == nil {
+		return
+	}
+
+	log.Printf("GameState: Host %s (%s) kicked player %s (%s).", sender.Id, sender.Name, target.Id, target.Name)
+	g.expel(target, messages.ErrorCodeKicked, "You were kicked from the room by the host.")
+	g.GameState.BroadcastSystemMessage(target.Name + " was kicked by the host.")
+}
+
+// handleBan kicks a player and stops them coming back for as long as the room lasts, either as the same
+// player (their resume token identifies them) or, if asked, from the same IP address
+func (g *Game) handleBan(sender *Player, msg messages.Message) {
+	state := g.GameState
+
+	var payload messages.BanPlayerPayload
+	if err := json.Unmarshal(msg.Payload, &payload); err != nil {
+		sender.SendError("Invalid ban format.")
+		return
+	}
+
+	target := g.hostTarget(sender, payload.PlayerID, "ban")
+	if target == nil {
+		return
+	}
+
+	state.bannedIds[target.Id] = true
+	if payload.ByIP && target.RemoteIP != "" {
+		state.bannedIPs[target.RemoteIP] = true
+	}
+
+	log.Printf("GameState: Host %s (%s) banned player %s (%s), by IP: %t.", sender.Id, sender.Name, target.Id, target.Name, payload.ByIP)
+	g.expel(target, messages.ErrorCodeBanned, "You were banned from the room by the host.")
+	state.BroadcastSystemMessage(target.Name + " was banned by the host.")
+}
+
+func (g *Game) handleTransferHost(sender *Player, msg messages.Message) {
+	state := g.GameState
+
+	var payload messages.TargetPlayerPayload
+	if err := json.Unmarshal(msg.Payload, &payload); err != nil {
+		sender.SendError("Invalid transfer host format.")
+		return
+	}
+
+	target := g.hostTarget(sender, payload.PlayerID, "make host")
+	if target == nil {
+		return
+	}
+	if state.playerIndex(target.Id) < 0 {
+		sender.SendError("Only players in the game can be made host.")
+		return
+	}
+	if state.disconnected[target.Id] != nil {
+		sender.SendError("That player has lost connection.")
+		return
+	}
+
+	state.HostId = target.Id
+	log.Printf("GameState: Host %s (%s) handed host to %s (%s).", sender.Id, sender.Name, target.Id, target.Name)
+	state.BroadcastSystemMessage(target.Name + " is now the host.")
+	state.broadcastPlayerUpdate()
+	g.reviewPendingJoins()
+}
+
+// expel takes a player out of the room altogether, whether they're playing, watching or waiting to join.
+// Removing them straight away rather than waiting for their connection to close keeps the drawer and the
+// current phase consistent. Assumes the lock is held.
+func (g *Game) expel(target *Player, code string, reason string) {
+	if !g.GameState.removePending(target) {
+		g.removePlayer(target)
+	}
+
+	target.SendErrorWithCode(code, reason)
+	target.Disconnect(DisconnectKicked)
+}
+
+// isBanned reports whether the host has banned this player, or anyone from their IP address
+func (g *GameState) isBanned(player *Player) bool {
+	return g.bannedIds[player.Id] || (player.RemoteIP != "" && g.bannedIPs[player.RemoteIP])
+}
@@ -0,0 +1,163 @@
+package game
+
+import (
+	"backend/messages"
+	"expvar"
+	"sync"
+	"sync/atomic"
+)
+
+const (
+	// Past this many queued frames, draw points that can be lost without breaking a stroke are dropped
+	outboundDropThreshold = 128
+	// Past this many queued frames the player can't keep up, so they're disconnected
+	outboundDisconnectThreshold = 512
+)
+
+var (
+	metricFramesQueued       = expvar.NewInt("outbound_frames_queued")         // Frames waiting across every player
+	metricFramesDropped      = expvar.NewInt("outbound_frames_dropped")        // Draw frames dropped for slow players
+	metricFramesCoalesced    = expvar.NewInt("outbound_frames_coalesced")      // Binary draw batches merged into one still queued
+	metricSlowConsumersDisco = expvar.NewInt("outbound_slow_consumers_closed") // Players disconnected for falling too far behind
+
+	// Deepest any one player's queue has been. Every player's sends race to raise it, so it's updated with
+	// compare and swap rather than as an expvar.Int.
+	queueDepthMax atomic.Int64
+)
+
+func init() {
+	expvar.Publish("outbound_queue_depth_max", expvar.Func(func() any { return queueDepthMax.Load() }))
+}
+
+func recordQueueDepth(depth int64) {
+	for {
+		current := queueDepthMax.Load()
+		if depth <= current || queueDepthMax.CompareAndSwap(current, depth) {
+			return
+		}
+	}
+}
+
+// Frame is a message waiting to be written to the player's connection
+type Frame struct {
+	Data   []byte
+	Binary bool
+
+	droppable  bool                        // Can be skipped if the player is falling behind
+	drawEvents []messages.DrawEventPayload // Unencoded binary draw batch, encoded when written so batches can merge
+}
+
+// outboundQueue holds a player's frames in the order they were sent until the write pump gets to them.
+// Sending never blocks, so a slow player can't hold up the game or anyone else.
+type outboundQueue struct {
+	mu     sync.Mutex
+	frames []Frame
+	closed bool
+	notify chan struct{} // Signalled when there's something for the write pump to do
+}
+
+type enqueueResult int
+
+const (
+	enqueued enqueueResult = iota
+	enqueueDropped
+	enqueueClosed
+	enqueueOverflow // The queue was full, it has been closed and the player should be disconnected
+)
+
+func (q *outboundQueue) signal() chan struct{} {
+	if q.notify == nil {
+		q.notify = make(chan struct{}, 1)
+	}
+	return q.notify
+}
+
+func (q *outboundQueue) wake() {
+	select {
+	case q.signal() <- struct{}{}:
+	default:
+	}
+}
+
+// wait returns a channel that is signalled when frames are pushed or the queue is closed
+func (q *outboundQueue) wait() <-chan struct{} {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.signal()
+}
+
+func (q *outboundQueue) push(frame Frame) enqueueResult {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.closed {
+		return enqueueClosed
+	}
+
+	depth := len(q.frames)
+	if frame.droppable && depth >= outboundDropThreshold {
+		metricFramesDropped.Add(1)
+		return enqueueDropped
+	}
+
+	// A binary draw batch behind another that hasn't been written yet can just join it
+	if frame.drawEvents != nil && depth > 0 && q.frames[depth-1].drawEvents != nil {
+		last := &q.frames[depth-1]
+		last.drawEvents = append(last.drawEvents, frame.drawEvents...)
+		metricFramesCoalesced.Add(1)
+		return enqueued
+	}
+
+	if depth >= outboundDisconnectThreshold {
+		// No point writing any of the backlog to someone about to be disconnected
+		metricFramesQueued.Add(-int64(depth))
+		q.frames = nil
+		q.closeLocked()
+		metricSlowConsumersDisco.Add(1)
+		return enqueueOverflow
+	}
+
+	q.frames = append(q.frames, frame)
+	metricFramesQueued.Add(1)
+	recordQueueDepth(int64(depth + 1))
+	q.wake()
+	return enqueued
+}
+
+// pop takes every queued frame. closed is true once the queue has been closed and drained. Returns nothing
+// if the queue is empty, in which case wait for the next signal.
+func (q *outboundQueue) pop() (frames []Frame, closed bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	frames = q.frames
+	q.frames = nil
+	metricFramesQueued.Add(-int64(len(frames)))
+	return frames, q.closed && len(frames) == 0
+}
+
+func (q *outboundQueue) depth() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return len(q.frames)
+}
+
+func (q *outboundQueue) isClosed() bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.closed
+}
+
+func (q *outboundQueue) close() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.closeLocked()
+}
+
+func (q *outboundQueue) closeLocked() {
+	if q.closed {
+		return
+	}
+	q.closed = true
+	q.wake()
+}
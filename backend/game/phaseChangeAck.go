@@ -3,12 +3,15 @@ package game
 import (
 	"backend/messages"
 	"encoding/json"
-	"slices"
+	"log"
+	"time"
 )
 
+// PhaseChangeHandler waits for players to confirm they've seen a phase change before moving on to it.
+// Anyone who hasn't acked by the deadline is dealt with according to the room's AFK policy.
 type PhaseChangeHandler struct {
 	HandlerToChangeTo GamePhaseHandler
-	AckedPlayers      []string
+	NotAcked          map[string]bool // IDs of players we're still waiting on
 }
 
 func (p *PhaseChangeHandler) Phase() GamePhase {
@@ -16,37 +19,78 @@ func (p *PhaseChangeHandler) Phase() GamePhase {
 }
 
 func (p *PhaseChangeHandler) StartPhase(gs *GameState) {
-	ackPayload := messages.PhaseChangeAckPayload{
-		NewPhase: p.HandlerToChangeTo.Phase().String(),
+	p.NotAcked = make(map[string]bool, len(gs.Players))
+	for _, player := range gs.Players {
+		// No point waiting on players we already know aren't responding, or who can't until they reconnect
+		if !gs.afk[player.Id] && gs.disconnected[player.Id] == nil {
+			p.NotAcked[player.Id] = true
+		}
 	}
 
-	turnEndMsg := messages.Message{Type: messages.PhaseChangeAckResponse, Payload: json.RawMessage(messages.MustMarshal(ackPayload))}
-	go gs.Broadcaster.Broadcast(turnEndMsg)
+	gs.timerForTimeout = time.NewTimer(gs.ackTimeout)
+
+	turnEndMsg := messages.Message{Type: messages.PhaseChangeAckResponse, Payload: json.RawMessage(messages.MustMarshal(p.ackPayload()))}
+	gs.Broadcaster.Broadcast(turnEndMsg)
 
 	return
 }
 
+func (p *PhaseChangeHandler) ackPayload() messages.PhaseChangeAckPayload {
+	return messages.PhaseChangeAckPayload{
+		NewPhase: p.HandlerToChangeTo.Phase().String(),
+	}
+}
+
 func (p *PhaseChangeHandler) HandleMessage(gs *GameState, player *Player, msg messages.Message) GamePhaseHandler {
-	if msg.Type == messages.ClientPhaseChangeAck && !slices.Contains(p.AckedPlayers, player.Id) {
+	if msg.Type == messages.ClientPhaseChangeAck && p.NotAcked[player.Id] {
 		var payload messages.PhaseChangeAckPayload
 		if err := json.Unmarshal(msg.Payload, &payload); err != nil {
 			player.SendError("Invalid phase change ack payload.")
 		} else if payload.NewPhase != p.HandlerToChangeTo.Phase().String() {
 			player.SendError("Sent the wrong phase in ack payload.")
 		} else {
-			p.AckedPlayers = append(p.AckedPlayers, player.Id)
+			delete(p.NotAcked, player.Id)
 		}
 	}
 
-	// TODO (JP): a bit brittle with disconnecting/reconnecting, use equivalence or use a _not acked_ list instead
-	if len(gs.Players) != len(p.AckedPlayers) {
+	if !p.allAcked(gs) {
 		return p
 	}
 
 	return p.HandlerToChangeTo
 }
 
+// allAcked reports whether everyone still in the game has acked. Players who have left or lost their
+// connection since the phase change started don't hold it up, and count as having acked if they reconnect.
+func (p *PhaseChangeHandler) allAcked(gs *GameState) bool {
+	for id := range p.NotAcked {
+		if gs.playerIndex(id) < 0 || gs.disconnected[id] != nil {
+			delete(p.NotAcked, id)
+		}
+	}
+	return len(p.NotAcked) == 0
+}
+
 func (p *PhaseChangeHandler) HandleTimeOut(gs *GameState) GamePhaseHandler {
-	// TODO (JP): if all players don't ack within a limit, remove them from the game and continue
-	return p
+	for id := range p.NotAcked {
+		i := gs.playerIndex(id)
+		if i < 0 {
+			continue
+		}
+		straggler := gs.Players[i]
+
+		switch gs.Settings.AfkPolicy {
+		case AfkPolicyRemove:
+			log.Printf("GameState: Player %s (%s) didn't ack %s in time, removing.", straggler.Id, straggler.Name, p.HandlerToChangeTo.Phase())
+			gs.BroadcastSystemMessage(straggler.Name + " was removed for not responding.")
+			// Closing their connection takes them out of the game the same way as leaving does
+			straggler.Disconnect(DisconnectUnresponsive)
+		default:
+			log.Printf("GameState: Player %s (%s) didn't ack %s in time, marking AFK.", straggler.Id, straggler.Name, p.HandlerToChangeTo.Phase())
+			gs.setAfk(straggler, true)
+		}
+	}
+
+	p.NotAcked = make(map[string]bool)
+	return p.HandlerToChangeTo
 }
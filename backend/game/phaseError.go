@@ -1,21 +1,49 @@
 package game
 
-import "backend/messages"
+import (
+	"backend/messages"
+	"encoding/json"
+	"log"
+)
 
-type ErrorHandler struct{}
+// ErrorHandler is where a room ends up when a phase handler fails. The game stops, but the players stay
+// connected and the host can take everyone back to the lobby to start again.
+type ErrorHandler struct {
+	Reason string // What went wrong, for the logs
+}
 
 func (p *ErrorHandler) Phase() GamePhase {
-	return GamePhaseGameOver
+	return GamePhaseError
 }
 
 func (p *ErrorHandler) StartPhase(gs *GameState) {
-	// todo
-	return
+	log.Printf("GameState: Entering Error phase: %s", p.Reason)
+	gs.IsActive = false
+
+	errorMsg := messages.Message{
+		Type: messages.TypeErrorResponse,
+		Payload: json.RawMessage(messages.MustMarshal(messages.ErrorPayload{
+			Message: "Something went wrong with the game. The host can return everyone to the lobby.",
+			Code:    messages.ErrorCodeGameFailed,
+		})),
+	}
+	gs.Broadcaster.Broadcast(errorMsg)
 }
 
 func (p *ErrorHandler) HandleMessage(gs *GameState, player *Player, msg messages.Message) GamePhaseHandler {
-	// todo
-	return p
+	if msg.Type != messages.ClientReturnToLobby {
+		log.Printf("GameState: Ignoring message type %s from player %s in Error phase.", msg.Type, player.Name)
+		return p
+	}
+
+	if player.Id != gs.HostId {
+		player.SendError("Only the host can return to the lobby.")
+		return p
+	}
+
+	log.Printf("GameState: Host %s (%s) is resetting the game after an error.", player.Id, player.Name)
+	gs.resetGame()
+	return ackPhaseTransitionTo(&WaitingInLobbyHandler{})
 }
 
 func (p *ErrorHandler) HandleTimeOut(gs *GameState) GamePhaseHandler {
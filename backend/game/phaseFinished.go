@@ -4,17 +4,31 @@ import (
 	"backend/messages"
 	"encoding/json"
 	"log"
+	"slices"
 	"time"
 )
 
-type RoundFinishedHandler struct{}
+type RoundFinishedHandler struct {
+	Skipped    bool // Ended early by a vote, so the drawer gets nothing
+	DrawerLeft bool // The drawer was removed, so CurrentDrawerIdx has moved back to someone who didn't draw
+}
 
 func (p *RoundFinishedHandler) Phase() GamePhase {
 	return GamePhaseRoundFinished
 }
 
 func (p *RoundFinishedHandler) StartPhase(gs *GameState) {
-	playerRoundScores := calculateRoundScores(gs)
+	drawerId := ""
+	if gs.CurrentDrawerIdx < 0 || gs.CurrentDrawerIdx >= len(gs.Players) {
+		log.Printf("GameState: Invalid drawer index %d, cannot calculate drawer bonus.", gs.CurrentDrawerIdx)
+	} else if !p.DrawerLeft {
+		drawerId = gs.Players[gs.CurrentDrawerIdx].Id
+	}
+
+	playerRoundScores := calculateRoundScores(gs, drawerId)
+	if p.Skipped && drawerId != "" {
+		playerRoundScores[drawerId] = 0
+	}
 
 	for _, player := range gs.Players {
 		if roundScore, ok := playerRoundScores[player.Id]; ok {
@@ -22,20 +36,30 @@ func (p *RoundFinishedHandler) StartPhase(gs *GameState) {
 		}
 	}
 
-	gs.PlayersWhoHaveDrawnThisRound = append(gs.PlayersWhoHaveDrawnThisRound, gs.Players[gs.CurrentDrawerIdx].Id)
+	if drawerId != "" && !slices.Contains(gs.PlayersWhoHaveDrawnThisRound, drawerId) {
+		gs.PlayersWhoHaveDrawnThisRound = append(gs.PlayersWhoHaveDrawnThisRound, drawerId)
+	}
 
-	finishDelay := 5 * time.Second
+	finishDelay := gs.Settings.TurnEndDelay
 	gs.timerForTimeout = time.NewTimer(finishDelay)
 	gs.turnEndTime = time.Now().Add(finishDelay)
 
-	gs.BroadcastSystemMessage("Turn over! The word was: " + gs.Word)
+	if gs.Word != "" {
+		gs.BroadcastSystemMessage("Turn over! The word was: " + gs.Word)
+	} else if p.DrawerLeft {
+		// They left while still picking, so there's no word to give away
+		gs.BroadcastSystemMessage("Turn over! The drawer left before picking a word.")
+	} else {
+		gs.BroadcastSystemMessage("Turn over!")
+	}
 	turnEndPayload := messages.TurnEndPayload{
 		CorrectWord: gs.Word,
 		Players:     gs.getPlayerInfoList(),
 		RoundScores: playerRoundScores,
+		Skipped:     p.Skipped,
 	}
 	turnEndMsg := messages.Message{Type: messages.TurnEndResponse, Payload: json.RawMessage(messages.MustMarshal(turnEndPayload))}
-	go gs.Broadcaster.Broadcast(turnEndMsg)
+	gs.Broadcaster.Broadcast(turnEndMsg)
 }
 
 func (p *RoundFinishedHandler) HandleMessage(gs *GameState, player *Player, msg messages.Message) GamePhaseHandler {
@@ -48,16 +72,16 @@ func (p *RoundFinishedHandler) HandleTimeOut(gs *GameState) GamePhaseHandler {
 	gs.CorrectGuessTimes = make(map[string]time.Time)
 	gs.Word = ""
 
-	// Check if game should end due to rounds
-	numPlayers := len(gs.Players)
-	if numPlayers > 0 && len(gs.PlayersWhoHaveDrawnThisRound) >= numPlayers {
+	// Check if game should end due to rounds. Players who drew and then left don't count towards it.
+	if len(gs.Players) > 0 && gs.everyoneHasDrawn() {
 		gs.CurrentRound++
 		gs.PlayersWhoHaveDrawnThisRound = make([]string, 0)
 		log.Printf("GameState: Round %d completed.", gs.CurrentRound)
+		gs.promoteSpectators()
 	}
 
-	if gs.CurrentRound >= gs.TotalRounds {
-		log.Printf("GameState: Final round (%d/%d) finished. Game Over.", gs.CurrentRound, gs.TotalRounds)
+	if gs.CurrentRound >= gs.Settings.TotalRounds {
+		log.Printf("GameState: Final round (%d/%d) finished. Game Over.", gs.CurrentRound, gs.Settings.TotalRounds)
 		return ackPhaseTransitionTo(&GameOverHandler{})
 	}
 
@@ -68,3 +92,12 @@ func (p *RoundFinishedHandler) HandleTimeOut(gs *GameState) GamePhaseHandler {
 		return ackPhaseTransitionTo(&WaitingInLobbyHandler{})
 	}
 }
+
+func (gs *GameState) everyoneHasDrawn() bool {
+	for _, player := range gs.Players {
+		if !slices.Contains(gs.PlayersWhoHaveDrawnThisRound, player.Id) {
+			return false
+		}
+	}
+	return true
+}
@@ -3,6 +3,6 @@ package game
 func ackPhaseTransitionTo(handler GamePhaseHandler) GamePhaseHandler {
 	return GamePhaseHandler(&PhaseChangeHandler{
 		HandlerToChangeTo: handler,
-		AckedPlayers:      make([]string, 0),
+		NotAcked:          make(map[string]bool),
 	})
 }
@@ -2,13 +2,19 @@ package game
 
 import (
 	"backend/messages"
+	"backend/moderation"
+	"backend/wordmatch"
 	"encoding/json"
 	"log"
 	"time"
+	"unicode/utf8"
 )
 
 type RoundInProgressHandler struct {
 	Word string
+
+	hintSchedule []time.Time // When each hint is due, consumed as they're revealed
+	matcher      *wordmatch.Matcher
 }
 
 func (p *RoundInProgressHandler) Phase() GamePhase {
@@ -26,14 +32,19 @@ func (p *RoundInProgressHandler) StartPhase(gs *GameState) {
 	drawer := gs.Players[gs.CurrentDrawerIdx]
 
 	gs.Word = p.Word
+	gs.Hints = nil
+	gs.Canvas.Reset()
+	p.matcher = wordmatch.NewMatcher(gs.Word, gs.Words.Aliases(gs.Word))
 	now := time.Now()
 	gs.TurnStartTime = now
-	gs.turnEndTime = now.Add(turnDuration)
-	gs.timerForTimeout = time.NewTimer(turnDuration)
+	gs.turnEndTime = now.Add(gs.Settings.TurnDuration)
+	p.hintSchedule = hintTimes(gs.Word, now, gs.Settings.TurnDuration)
+	p.armTimer(gs)
 
 	turnPayloadBase := messages.TurnStartPayload{
 		CurrentDrawerID: drawer.Id,
-		WordLength:      len(gs.Word),
+		WordLength:      utf8.RuneCountInString(gs.Word),
+		Mask:            buildWordMask(gs.Word),
 		Players:         gs.getPlayerInfoList(), // Assumes lock held
 		TurnEndTime:     gs.turnEndTime.UnixMilli(),
 	}
@@ -41,61 +52,109 @@ func (p *RoundInProgressHandler) StartPhase(gs *GameState) {
 	drawerPayload := turnPayloadBase
 	drawerPayload.Word = gs.Word
 	log.Printf("GameState: Sending TurnStart (with word) to drawer %s", drawer.Name)
-	go drawer.SendMessage(messages.TurnStartResponse, drawerPayload)
+	drawer.SendMessage(messages.TurnStartResponse, drawerPayload)
 
 	guesserPayload := turnPayloadBase
 	msg := messages.Message{Type: messages.TurnStartResponse, Payload: json.RawMessage(messages.MustMarshal(guesserPayload))}
-	playersToSendTo := make([]*Player, 0, len(gs.Players)-1)
-	for i, p := range gs.Players {
-		if i != gs.CurrentDrawerIdx {
-			playersToSendTo = append(playersToSendTo, p)
-		}
-	}
-	log.Printf("GameState: Sending TurnStart (no word) to %d guessers", len(playersToSendTo))
-	go gs.Broadcaster.BroadcastToPlayers(msg, playersToSendTo)
+	playersToSendTo := gs.watchersExcept(gs.CurrentDrawerIdx)
+	log.Printf("GameState: Sending TurnStart (no word) to %d guessers and spectators", len(playersToSendTo))
+	gs.Broadcaster.BroadcastToPlayers(msg, playersToSendTo)
 
 	gs.BroadcastSystemMessage(drawer.Name + " is drawing!")
 	return
 }
 
 func (p *RoundInProgressHandler) HandleMessage(gs *GameState, player *Player, msg messages.Message) GamePhaseHandler {
-	if msg.Type == messages.ClientGuess && !gs.isDrawer(player) {
-		if _, alreadyGuessed := gs.CorrectGuessTimes[player.Id]; alreadyGuessed {
+	if msg.Type == messages.ClientChat {
+		text, ok := chatText(player, msg)
+		if !ok {
 			return p
 		}
-
+		// Chat from players still guessing is treated as a guess, so nobody can type the answer where
+		// everyone can see it
+		if gs.knowsWord(player) {
+			gs.sendGuessedChat(player, text)
+			return p
+		}
+		return p.handleGuess(gs, player, text)
+	} else if msg.Type == messages.ClientGuess {
 		var guessPayload messages.GuessPayload
 		if err := json.Unmarshal(msg.Payload, &guessPayload); err != nil {
 			player.SendError("Invalid guess format.")
 			return p
 		}
 
-		correct := guessPayload.Guess == gs.Word
-
-		if correct {
-			gs.CorrectGuessTimes[player.Id] = time.Now()
-			gs.BroadcastSystemMessage(player.Name + " guessed the word!")
-
-			if gs.checkAllGuessed() {
-				return ackPhaseTransitionTo(&RoundFinishedHandler{})
+		// The drawer and players who have already guessed have nothing left to guess, so it's chat for
+		// the players who know the word
+		if gs.knowsWord(player) {
+			if text := moderation.Sanitise(guessPayload.Guess); text != "" {
+				gs.sendGuessedChat(player, text)
 			}
-		} else {
-			gs.BroadcastChatMessage(player.Name, guessPayload.Guess)
+			return p
 		}
+		return p.handleGuess(gs, player, guessPayload.Guess)
 	} else if msg.Type == messages.ClientDrawEvent && gs.isDrawer(player) {
-		drawMsg := messages.Message{Type: messages.DrawEventBroadcastResponse, Payload: msg.Payload}
-		playersToSendTo := make([]*Player, 0, len(gs.Players)-1)
-		for _, p := range gs.Players {
-			if p != nil && p.Id != player.Id {
-				playersToSendTo = append(playersToSendTo, p)
-			}
+		var drawPayload messages.DrawEventPayload
+		if err := json.Unmarshal(msg.Payload, &drawPayload); err != nil {
+			player.SendError("Invalid draw event format.")
+			return p
+		}
+		if (drawPayload.EventType == messages.DrawEventUndo || drawPayload.EventType == messages.DrawEventRedo) && gs.Canvas.IsTruncated() {
+			player.SendError("The drawing is too big to undo or redo any more.")
+			return p
+		}
+		if !gs.Canvas.Apply(&drawPayload) {
+			return p
 		}
 
-		go gs.Broadcaster.BroadcastToPlayers(drawMsg, playersToSendTo)
+		playersToSendTo := gs.watchersExcept(gs.CurrentDrawerIdx)
+		if drawPayload.EventType != messages.DrawEventStart && drawPayload.EventType != messages.DrawEventDraw && drawPayload.EventType != messages.DrawEventEnd {
+			// The drawer applies edits like undo when the server confirms them, so everyone agrees on the result
+			playersToSendTo = append(playersToSendTo, player)
+		}
+
+		gs.Broadcaster.BroadcastDrawEvent(drawPayload, playersToSendTo)
+	}
+	return p
+}
+
+func (p *RoundInProgressHandler) handleGuess(gs *GameState, player *Player, guess string) GamePhaseHandler {
+	switch p.matcher.Check(guess) {
+	case wordmatch.Correct:
+		gs.CorrectGuessTimes[player.Id] = time.Now()
+		gs.BroadcastSystemMessage(player.Name + " guessed the word!")
+
+		if gs.checkAllGuessed() {
+			return ackPhaseTransitionTo(&RoundFinishedHandler{})
+		}
+	case wordmatch.Close:
+		// Only the guesser sees close guesses, echoing them to everyone would give the word away
+		player.SendSystemMessage("'" + guess + "' is close!")
+	default:
+		if text, ok := gs.moderate(player, moderation.Sanitise(guess)); ok && text != "" {
+			gs.BroadcastGuessMessage(player.Name, text)
+		}
 	}
 	return p
 }
 
 func (p *RoundInProgressHandler) HandleTimeOut(gs *GameState) GamePhaseHandler {
+	if len(p.hintSchedule) == 0 {
+		log.Println("GameState: Turn timer ran out.")
+		return ackPhaseTransitionTo(&RoundFinishedHandler{})
+	}
+
+	p.hintSchedule = p.hintSchedule[1:]
+	gs.revealHint()
+	p.armTimer(gs)
 	return p
 }
+
+// armTimer sets the timeout for whichever comes next, the next hint or the end of the turn
+func (p *RoundInProgressHandler) armTimer(gs *GameState) {
+	next := gs.turnEndTime
+	if len(p.hintSchedule) > 0 {
+		next = p.hintSchedule[0]
+	}
+	gs.timerForTimeout = time.NewTimer(time.Until(next))
+}
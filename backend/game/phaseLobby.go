@@ -1,6 +1,10 @@
 package game
 
-import "backend/messages"
+import (
+	"backend/messages"
+	"encoding/json"
+	"log"
+)
 
 type WaitingInLobbyHandler struct{}
 
@@ -20,11 +24,43 @@ func (p *WaitingInLobbyHandler) HandleMessage(gs *GameState, player *Player, msg
 			gs.IsActive = true
 			return ackPhaseTransitionTo(&RoundSetupHandler{WordToPickFrom: nil})
 		}
+	} else if msg.Type == messages.ClientUpdateSettings {
+		p.handleUpdateSettings(gs, player, msg)
+	} else if msg.Type == messages.ClientSetSpectating {
+		var payload messages.SetSpectatingPayload
+		if err := json.Unmarshal(msg.Payload, &payload); err != nil {
+			player.SendError("Invalid spectating format.")
+		} else if payload.Spectating {
+			gs.makeSpectator(player)
+		}
 	}
 
 	return p
 }
 
+func (p *WaitingInLobbyHandler) handleUpdateSettings(gs *GameState, player *Player, msg messages.Message) {
+	if player.Id != gs.HostId {
+		player.SendError("Only the host can change the game settings.")
+		return
+	}
+
+	var payload messages.GameSettingsPayload
+	if err := json.Unmarshal(msg.Payload, &payload); err != nil {
+		player.SendError("Invalid settings format.")
+		return
+	}
+
+	settings := settingsFromPayload(payload)
+	if err := settings.Validate(); err != nil {
+		player.SendError("Invalid settings: " + err.Error() + ".")
+		return
+	}
+
+	gs.Settings = settings
+	log.Printf("GameState: Host %s (%s) updated settings: %+v", player.Id, player.Name, settings)
+	gs.broadcastSettings()
+}
+
 func (p *WaitingInLobbyHandler) HandleTimeOut(gs *GameState) GamePhaseHandler {
 	return p
 }
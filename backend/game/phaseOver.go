@@ -15,6 +15,7 @@ func (p *GameOverHandler) Phase() GamePhase {
 func (p *GameOverHandler) StartPhase(gs *GameState) {
 	log.Println("GameState: Entering GameOver phase.")
 	gs.IsActive = false
+	gs.promoteSpectators()
 
 	finalScoresPayload := messages.GameFinishedPayload{
 		Players: gs.getPlayerInfoList(),
@@ -26,12 +27,24 @@ func (p *GameOverHandler) StartPhase(gs *GameState) {
 	}
 
 	log.Printf("GameState: Broadcasting GameFinished message with %d players.", len(finalScoresPayload.Players))
-	go gs.Broadcaster.Broadcast(gameOverMsg)
+	gs.Broadcaster.Broadcast(gameOverMsg)
 }
 
 func (p *GameOverHandler) HandleMessage(gs *GameState, player *Player, msg messages.Message) GamePhaseHandler {
-	log.Printf("GameState: Ignoring message type %s from player %s in GameOver phase.", msg.Type, player.Name)
-	return p
+	if msg.Type != messages.ClientReturnToLobby {
+		log.Printf("GameState: Ignoring message type %s from player %s in GameOver phase.", msg.Type, player.Name)
+		return p
+	}
+
+	if player.Id != gs.HostId {
+		player.SendError("Only the host can start another game.")
+		return p
+	}
+
+	// Same room, players and settings, fresh scores
+	log.Printf("GameState: Host %s (%s) is taking everyone back to the lobby to play again.", player.Id, player.Name)
+	gs.resetGame()
+	return ackPhaseTransitionTo(&WaitingInLobbyHandler{})
 }
 
 func (p *GameOverHandler) HandleTimeOut(gs *GameState) GamePhaseHandler {
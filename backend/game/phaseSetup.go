@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"log"
 	"math/rand"
+	"slices"
 	"time"
 )
 
@@ -18,17 +19,13 @@ func (p *RoundSetupHandler) Phase() GamePhase {
 }
 
 func (p *RoundSetupHandler) StartPhase(gs *GameState) {
-	gs.turnEndTime = time.Now().Add(10 * time.Second)
-	gs.timerForTimeout = time.NewTimer(10 * time.Second)
+	gs.turnEndTime = time.Now().Add(gs.Settings.WordChoiceDuration)
+	gs.timerForTimeout = time.NewTimer(gs.Settings.WordChoiceDuration)
 
 	gs.CurrentDrawerIdx = (gs.CurrentDrawerIdx + 1) % len(gs.Players)
 	newDrawer := gs.Players[gs.CurrentDrawerIdx]
 
-	wordChoices := make([]string, 3)
-	perms := rand.Perm(len(words))
-	for i, r := range perms[:len(wordChoices)] {
-		wordChoices[i] = words[r]
-	}
+	wordChoices := gs.Words.Deal(gs.Settings.WordChoiceCount)
 	p.WordToPickFrom = &wordChoices
 
 	turnPayloadBase := messages.TurnSetupPayload{
@@ -40,18 +37,13 @@ func (p *RoundSetupHandler) StartPhase(gs *GameState) {
 	drawerPayload := turnPayloadBase
 	drawerPayload.WordChoices = *p.WordToPickFrom
 	log.Printf("GameState: Sending TurnSetup (with word choices) to drawer %s", newDrawer.Name)
-	go newDrawer.SendMessage(messages.TurnSetupResponse, drawerPayload)
+	newDrawer.SendMessage(messages.TurnSetupResponse, drawerPayload)
 
 	guesserPayload := turnPayloadBase
 	msg := messages.Message{Type: messages.TurnSetupResponse, Payload: json.RawMessage(messages.MustMarshal(guesserPayload))}
-	playersToSendTo := make([]*Player, 0, len(gs.Players)-1)
-	for i, p := range gs.Players {
-		if i != gs.CurrentDrawerIdx {
-			playersToSendTo = append(playersToSendTo, p)
-		}
-	}
-	log.Printf("GameState: Sending TurnSetup (no word choices) to %d guessers", len(playersToSendTo))
-	go gs.Broadcaster.BroadcastToPlayers(msg, playersToSendTo)
+	playersToSendTo := gs.watchersExcept(gs.CurrentDrawerIdx)
+	log.Printf("GameState: Sending TurnSetup (no word choices) to %d guessers and spectators", len(playersToSendTo))
+	gs.Broadcaster.BroadcastToPlayers(msg, playersToSendTo)
 
 	gs.BroadcastSystemMessage(newDrawer.Name + " is choosing a word.")
 	return
@@ -69,12 +61,32 @@ func (p *RoundSetupHandler) HandleMessage(gs *GameState, player *Player, msg mes
 
 	var roundWordPayload messages.SelectRoundWordPayload
 	if err := json.Unmarshal(msg.Payload, &roundWordPayload); err != nil {
-		player.SendError("Invalid guess format.")
+		player.SendError("Invalid word selection format.")
 		return p
 	}
 
-	// TODO: check that player hasn't picked a random word, make sure it's in the list of p.WordToPickFrom
-	return ackPhaseTransitionTo(&RoundInProgressHandler{Word: roundWordPayload.Word})
+	word := roundWordPayload.Word
+	if p.WordToPickFrom == nil || !slices.Contains(*p.WordToPickFrom, word) {
+		if !gs.Settings.AllowCustomWords {
+			player.SendErrorWithCode(messages.ErrorCodeWordNotOffered, "You can only pick one of the words offered.")
+			return p
+		}
+
+		customWord, err := validateCustomWord(word)
+		if err != nil {
+			player.SendErrorWithCode(messages.ErrorCodeInvalidCustomWord, "Invalid custom word: "+err.Error()+".")
+			return p
+		}
+		// Whatever the room's moderation action, there's no drawing a masked word
+		if gs.blocklist != nil && gs.blocklist.Contains(customWord) {
+			player.SendErrorWithCode(messages.ErrorCodeInvalidCustomWord, "Invalid custom word: that word isn't allowed.")
+			return p
+		}
+		log.Printf("GameState: Drawer %s chose custom word", player.Name)
+		word = customWord
+	}
+
+	return ackPhaseTransitionTo(&RoundInProgressHandler{Word: word})
 }
 
 func (p *RoundSetupHandler) HandleTimeOut(gs *GameState) GamePhaseHandler {
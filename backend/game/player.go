@@ -4,6 +4,9 @@ import (
 	"backend/messages"
 	"encoding/json"
 	"log"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/gorilla/websocket"
 )
@@ -13,94 +16,234 @@ type Player struct {
 	Id           string
 	Name         string
 	Score        int
+	ResumeToken  string // Lets the client reconnect as this player, see Game.DisconnectPlayer
+	RemoteIP     string // Where the client connected from, for IP bans
 	Conn         *websocket.Conn
+	ConnConfig   ConnConfig
 	Unregister   chan *Player
 	GameMessages chan GameMessage
-	Send         chan []byte
+	RoomClosed   <-chan struct{} // Closed when the room shuts down, so the pumps stop handing things to it
+
+	JoinAsSpectator bool        // Asked to watch rather than play when connecting
+	BinaryDraw      bool        // Negotiated messages.DrawBinarySubprotocol, so gets batched binary draw events
+	waitingToPlay   bool        // Spectator who will be moved into the game at the next round
+	admitted        atomic.Bool // Let into the game, so room broadcasts reach them. See Admitted
+
+	outbound         outboundQueue
+	batchMu          sync.Mutex // Guards drawBatch
+	drawBatch        []messages.DrawEventPayload
+	disconnectReason atomic.Int32
+	limiter          *rateLimiter // Only touched by ReadPump
 }
 
 // readPump pumps messages from the WebSocket connection to the hub.
 func (p *Player) ReadPump() {
 	defer func() {
-		p.Unregister <- p
+		select {
+		case p.Unregister <- p:
+		case <-p.RoomClosed:
+		}
 		_ = p.Conn.Close()
 
-		log.Printf("Player %s (%s) disconnected and readPump cleaned up", p.Id, p.Name)
+		log.Printf("Player %s (%s) disconnected (%s) and readPump cleaned up", p.Id, p.Name, p.DisconnectReason())
 	}()
 
+	// Every pong, or any other message, shows the client is still there and pushes the deadline back
+	p.Conn.SetReadLimit(p.ConnConfig.MaxMessageSize)
+	_ = p.Conn.SetReadDeadline(time.Now().Add(p.ConnConfig.PongWait))
+	p.Conn.SetPongHandler(func(string) error {
+		return p.Conn.SetReadDeadline(time.Now().Add(p.ConnConfig.PongWait))
+	})
+
 	for {
-		_, messageBytes, err := p.Conn.ReadMessage()
+		messageType, messageBytes, err := p.Conn.ReadMessage()
 		if err != nil {
 			if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseAbnormalClosure) {
 				log.Printf("Player %s (%s) read error: %v", p.Id, p.Name, err)
 			} else {
 				log.Printf("Player %s (%s) connection closed normally.", p.Id, p.Name)
 			}
+			p.setDisconnectReason(disconnectReasonFor(err))
 			break
 		}
+		_ = p.Conn.SetReadDeadline(time.Now().Add(p.ConnConfig.PongWait))
+
+		if messageType == websocket.BinaryMessage {
+			if !p.readDrawFrame(messageBytes) {
+				break
+			}
+			continue
+		}
 
 		var msg messages.Message
 		if err := json.Unmarshal(messageBytes, &msg); err != nil {
 			log.Printf("Player %s (%s): Error unmarshalling message: %v", p.Id, p.Name, err)
-			p.SendError("Invalid message format")
+			if !p.rejectFrame("Invalid message format") {
+				break
+			}
+			continue
+		}
+
+		if result := p.checkRate(msg.Type); result == rateExceeded {
+			break
+		} else if result == rateThrottled {
+			continue
+		}
+		p.deliver(msg)
+	}
+}
+
+// deliver hands a message to the game, unless the room has gone away
+func (p *Player) deliver(msg messages.Message) {
+	select {
+	case p.GameMessages <- GameMessage{p, msg}:
+	case <-p.RoomClosed:
+	}
+}
+
+// readDrawFrame passes each event in a binary draw frame on to the game as if it had been sent as JSON.
+// It returns false if the client has been disconnected for going over the rate limit.
+func (p *Player) readDrawFrame(frame []byte) bool {
+	if !p.BinaryDraw {
+		return p.rejectFrame("Binary messages need the " + messages.DrawBinarySubprotocol + " protocol")
+	}
+
+	events, err := messages.DecodeDrawFrame(frame)
+	if err != nil {
+		log.Printf("Player %s (%s): Error decoding draw frame: %v", p.Id, p.Name, err)
+		return p.rejectFrame("Invalid draw frame")
+	}
+
+	for _, event := range events {
+		switch p.checkRate(messages.ClientDrawEvent) {
+		case rateExceeded:
+			return false
+		case rateThrottled:
 			continue
 		}
+		p.deliver(messages.Message{Type: messages.ClientDrawEvent, Payload: messages.MustMarshal(event)})
+	}
+	return true
+}
 
-		p.GameMessages <- GameMessage{p, msg}
+// rejectFrame tells the client a frame they sent couldn't be read. It's charged to the rate limit like any
+// other message so a client can't get an error back for every bit of junk it sends. It returns false if the
+// client has been disconnected for going over the rate limit.
+func (p *Player) rejectFrame(errMsg string) bool {
+	switch p.checkRate(invalidFrame) {
+	case rateExceeded:
+		return false
+	case rateAllowed:
+		p.SendError(errMsg)
 	}
+	return true
 }
 
-// writePump pumps messages from the player's Send channel to the WebSocket connection.
+// writePump pumps messages from the player's outbound queue to the WebSocket connection, and pings the
+// client so ReadPump can tell when it has gone.
 func (p *Player) WritePump() {
+	pingTicker := time.NewTicker(p.ConnConfig.PingInterval)
 	defer func() {
+		pingTicker.Stop()
 		p.Conn.Close()
 		log.Printf("Player %s (%s) writePump stopped.", p.Id, p.Name)
 	}()
 
 	for {
+		// Check for a ping each time round, as a busy queue might never leave us waiting below
 		select {
-		case message, ok := <-p.Send:
-			if !ok {
-				log.Printf("Player %s (%s): Room closed send channel.", p.Id, p.Name)
-				_ = p.Conn.WriteMessage(websocket.CloseMessage, []byte{})
+		case <-pingTicker.C:
+			if err := p.writePing(); err != nil {
 				return
 			}
+		default:
+		}
 
-			w, err := p.Conn.NextWriter(websocket.TextMessage)
-			if err != nil {
-				log.Printf("Player %s (%s) write error getting writer: %v", p.Id, p.Name, err)
-				return
-			}
-			_, err = w.Write(message)
-			if err != nil {
-				log.Printf("Player %s (%s) write error writing message: %v", p.Id, p.Name, err)
-				_ = w.Close()
-				return
+		frames, closed := p.outbound.pop()
+		if closed {
+			log.Printf("Player %s (%s): Outbound queue closed.", p.Id, p.Name)
+			_ = p.Conn.SetWriteDeadline(time.Now().Add(p.ConnConfig.WriteWait))
+			_ = p.Conn.WriteMessage(websocket.CloseMessage, []byte{})
+			return
+		}
+
+		if len(frames) == 0 {
+			select {
+			case <-p.outbound.wait():
+			case <-pingTicker.C:
+				if err := p.writePing(); err != nil {
+					return
+				}
 			}
+			continue
+		}
 
-			if err := w.Close(); err != nil {
-				log.Printf("Player %s (%s) writer close error: %v", p.Id, p.Name, err)
+		for _, frame := range frames {
+			if err := p.writeFrame(frame); err != nil {
+				log.Printf("Player %s (%s) write error: %v", p.Id, p.Name, err)
+				p.setDisconnectReason(disconnectReasonFor(err))
+				p.outbound.close()
 				return
 			}
 		}
 	}
 }
 
+func (p *Player) writePing() error {
+	_ = p.Conn.SetWriteDeadline(time.Now().Add(p.ConnConfig.WriteWait))
+	if err := p.Conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+		log.Printf("Player %s (%s) ping error: %v", p.Id, p.Name, err)
+		p.setDisconnectReason(disconnectReasonFor(err))
+		p.outbound.close()
+		return err
+	}
+	return nil
+}
+
+func (p *Player) writeFrame(frame Frame) error {
+	frameType := websocket.TextMessage
+	data := frame.Data
+	if frame.drawEvents != nil {
+		frameType = websocket.BinaryMessage
+		data = messages.EncodeDrawFrame(frame.drawEvents)
+	} else if frame.Binary {
+		frameType = websocket.BinaryMessage
+	}
+
+	_ = p.Conn.SetWriteDeadline(time.Now().Add(p.ConnConfig.WriteWait))
+	w, err := p.Conn.NextWriter(frameType)
+	if err != nil {
+		return err
+	}
+	if _, err = w.Write(data); err != nil {
+		_ = w.Close()
+		return err
+	}
+	return w.Close()
+}
+
 func (p *Player) SendError(errMsg string) {
+	p.SendErrorWithCode("", errMsg)
+}
+
+// SendErrorWithCode sends an error with a code from messages.ErrorCode* so the client can tell errors apart
+func (p *Player) SendErrorWithCode(code string, errMsg string) {
 	if p == nil {
 		return
 	}
-	payload := messages.ErrorPayload{Message: errMsg}
+	payload := messages.ErrorPayload{Message: errMsg, Code: code}
 
 	msg := messages.MustMarshal(messages.Message{Type: messages.TypeErrorResponse, Payload: json.RawMessage(messages.MustMarshal(payload))})
-	// Use a non-blocking send
-	select {
-	case p.Send <- msg:
-	default:
+	if !p.SendBytes(msg) {
 		log.Printf("Player %s (%s): Failed to send error message '%s', Send channel likely closed.", p.Id, p.Name, errMsg)
 	}
 }
 
+// SendSystemMessage sends a system chat message that only this player sees
+func (p *Player) SendSystemMessage(message string) {
+	p.SendMessage(messages.ChatResponse, messages.ChatPayload{SenderName: "System", Message: message, IsSystem: true})
+}
+
 // SendMessage sends any message type to this player (non-blocking).
 func (p *Player) SendMessage(msgType string, payload any) {
 	if p == nil {
@@ -108,11 +251,83 @@ func (p *Player) SendMessage(msgType string, payload any) {
 	}
 
 	msg := messages.MustMarshal(messages.Message{Type: msgType, Payload: messages.MustMarshal(payload)})
+	if !p.SendBytes(msg) {
+		log.Printf("Player %s (%s): Send channel full/closed for message type %s.", p.Id, p.Name, msgType)
+	}
+}
 
-	// Use non-blocking send to avoid deadlocks if writePump is stuck or channel closed
-	select {
-	case p.Send <- msg:
+// SendBytes queues an already marshalled message for the write pump. It never blocks, so it returns false
+// if the message was dropped because the player's connection has gone or can't keep up.
+func (p *Player) SendBytes(msg []byte) bool {
+	// Draw events waiting for the next batch happened before this message, so they go first
+	p.FlushDrawEvents()
+	return p.sendFrame(Frame{Data: msg})
+}
+
+func (p *Player) sendFrame(frame Frame) bool {
+	switch p.outbound.push(frame) {
+	case enqueued:
+		return true
+	case enqueueOverflow:
+		log.Printf("Player %s (%s): Too far behind on messages, disconnecting.", p.Id, p.Name)
+		p.setDisconnectReason(DisconnectTooSlow)
+		return false
 	default:
-		log.Printf("Player %s (%s): Send channel full/closed for message type %s.", p.Id, p.Name, msgType)
+		return false
+	}
+}
+
+// QueueDepth is how many frames are waiting to be written to the player
+func (p *Player) QueueDepth() int {
+	return p.outbound.depth()
+}
+
+// Admitted reports whether the game has let the player in. Until then they may be waiting on the host, so
+// they only get messages sent to them directly and none of the room's broadcasts.
+func (p *Player) Admitted() bool {
+	return p.admitted.Load()
+}
+
+// SendDrawEvent sends a draw event in whichever format the player negotiated. Binary draw events are held
+// until the next FlushDrawEvents so they go out in batches. JSON points mid-stroke may be dropped if the
+// player is falling behind, which only makes the line a little less smooth.
+func (p *Player) SendDrawEvent(event messages.DrawEventPayload, jsonMsg []byte) bool {
+	if !p.BinaryDraw {
+		p.FlushDrawEvents()
+		return p.sendFrame(Frame{Data: jsonMsg, droppable: event.EventType == messages.DrawEventDraw})
+	}
+
+	p.batchMu.Lock()
+	defer p.batchMu.Unlock()
+
+	// Nothing flushes the batch once the connection has gone, so it would only grow until they reconnect
+	if p.outbound.isClosed() {
+		p.drawBatch = nil
+		return false
+	}
+	p.drawBatch = append(p.drawBatch, event)
+	return true
+}
+
+// FlushDrawEvents queues any batched draw events to go out as a single binary frame
+func (p *Player) FlushDrawEvents() {
+	if !p.BinaryDraw {
+		return
+	}
+
+	p.batchMu.Lock()
+	defer p.batchMu.Unlock()
+
+	if len(p.drawBatch) == 0 {
+		return
+	}
+	if !p.sendFrame(Frame{drawEvents: p.drawBatch}) {
+		log.Printf("Player %s (%s): Dropped batch of %d draw events.", p.Id, p.Name, len(p.drawBatch))
 	}
+	p.drawBatch = nil
+}
+
+// CloseSend stops the write pump once it has written what's already queued. Safe to call more than once.
+func (p *Player) CloseSend() {
+	p.outbound.close()
 }
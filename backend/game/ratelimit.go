@@ -0,0 +1,129 @@
+package game
+
+import (
+	"backend/messages"
+	"expvar"
+	"log"
+	"time"
+)
+
+var (
+	metricMessagesThrottled  = expvar.NewInt("ratelimit_messages_throttled")    // Client messages dropped for going over a rate limit
+	metricFloodersDisconnect = expvar.NewInt("ratelimit_flooders_disconnected") // Players disconnected for ignoring rate limits
+)
+
+// RateLimit is a token bucket: Burst messages can be sent at once, refilling at PerSecond
+type RateLimit struct {
+	PerSecond float64
+	Burst     int
+}
+
+// RateLimits are the budgets for each kind of message a client sends. Draw events come thick and fast while
+// someone is drawing, so get their own budget rather than eating into the one for guesses.
+type RateLimits struct {
+	Draw    RateLimit // Draw events, counted individually even when batched into one binary frame
+	Guess   RateLimit // Guesses and chat
+	Control RateLimit // Everything else, including frames that couldn't be read
+	// Every throttled message uses up one of these, when they run out the client is disconnected
+	Abuse RateLimit
+}
+
+func DefaultRateLimits() RateLimits {
+	return RateLimits{
+		Draw:    RateLimit{PerSecond: 120, Burst: 240},
+		Guess:   RateLimit{PerSecond: 2, Burst: 5},
+		Control: RateLimit{PerSecond: 5, Burst: 10},
+		Abuse:   RateLimit{PerSecond: 2, Burst: 50},
+	}
+}
+
+type tokenBucket struct {
+	limit  RateLimit
+	tokens float64
+	last   time.Time
+}
+
+func newTokenBucket(limit RateLimit, now time.Time) *tokenBucket {
+	return &tokenBucket{limit: limit, tokens: float64(limit.Burst), last: now}
+}
+
+// take uses up a token if there's one left
+func (b *tokenBucket) take(now time.Time) bool {
+	b.tokens = min(float64(b.limit.Burst), b.tokens+now.Sub(b.last).Seconds()*b.limit.PerSecond)
+	b.last = now
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+type rateResult int
+
+const (
+	rateAllowed   rateResult = iota
+	rateThrottled            // Drop the message
+	rateExceeded             // Drop the message and disconnect the client
+)
+
+// How often a throttled client is told they're sending too fast, so the warnings aren't a flood of their own
+const throttleWarningInterval = time.Second
+
+// rateLimiter tracks one player's budgets. It's only used from their ReadPump, so needs no locking.
+type rateLimiter struct {
+	draw, guess, control, abuse *tokenBucket
+	lastWarning                 time.Time
+}
+
+func newRateLimiter(limits RateLimits) *rateLimiter {
+	now := time.Now()
+	return &rateLimiter{
+		draw:    newTokenBucket(limits.Draw, now),
+		guess:   newTokenBucket(limits.Guess, now),
+		control: newTokenBucket(limits.Control, now),
+		abuse:   newTokenBucket(limits.Abuse, now),
+	}
+}
+
+// Stands in for the message type of a frame that couldn't be read, which is charged to the control budget
+const invalidFrame = "invalid frame"
+
+func (l *rateLimiter) bucketFor(msgType string) *tokenBucket {
+	switch msgType {
+	case messages.ClientDrawEvent:
+		return l.draw
+	case messages.ClientGuess, messages.ClientChat:
+		return l.guess
+	default:
+		return l.control
+	}
+}
+
+// checkRate decides whether a message from the client goes through, telling them when they're being
+// throttled and disconnecting them if they keep it up
+func (p *Player) checkRate(msgType string) rateResult {
+	if p.limiter == nil {
+		p.limiter = newRateLimiter(p.ConnConfig.RateLimits)
+	}
+
+	now := time.Now()
+	if p.limiter.bucketFor(msgType).take(now) {
+		return rateAllowed
+	}
+
+	metricMessagesThrottled.Add(1)
+	if !p.limiter.abuse.take(now) {
+		metricFloodersDisconnect.Add(1)
+		log.Printf("Player %s (%s) kept going over the rate limit, disconnecting.", p.Id, p.Name)
+		p.SendErrorWithCode(messages.ErrorCodeRateLimited, "Disconnected for sending too many messages.")
+		p.Disconnect(DisconnectFlooding)
+		return rateExceeded
+	}
+
+	if now.Sub(p.limiter.lastWarning) >= throttleWarningInterval {
+		p.limiter.lastWarning = now
+		log.Printf("Player %s (%s) is over the rate limit for %s messages.", p.Id, p.Name, msgType)
+		p.SendErrorWithCode(messages.ErrorCodeRateLimited, "You're sending messages too fast, some were ignored.")
+	}
+	return rateThrottled
+}
@@ -1,7 +1,6 @@
 package game
 
 import (
-	"log"
 	"time"
 )
 
@@ -9,11 +8,12 @@ const (
 	baseScore          = 300
 	maxTimePenalty     = 225
 	firstGuessBonus    = 100
+	hintPenalty        = 30 // Per hint revealed before the guess
 	drawerPartialBonus = 50
 	drawerFullBonus    = 100
 )
 
-func calculateGuesserScoreAtTime(turnStartTime, guessTime time.Time, turnDuration time.Duration, isFirstGuesser bool) int {
+func calculateGuesserScoreAtTime(turnStartTime, guessTime time.Time, turnDuration time.Duration, isFirstGuesser bool, hintsRevealed int) int {
 	timeTaken := guessTime.Sub(turnStartTime)
 
 	timeRatio := float64(timeTaken) / float64(turnDuration)
@@ -25,6 +25,7 @@ func calculateGuesserScoreAtTime(turnStartTime, guessTime time.Time, turnDuratio
 	}
 
 	score := baseScore - int(float64(maxTimePenalty)*timeRatio)
+	score -= hintsRevealed * hintPenalty
 
 	if isFirstGuesser {
 		score += firstGuessBonus
@@ -32,13 +33,12 @@ func calculateGuesserScoreAtTime(turnStartTime, guessTime time.Time, turnDuratio
 	return score
 }
 
-func calculateRoundScores(gs *GameState) map[string]int {
+// calculateRoundScores works out what everyone earned this turn. drawerId is who gets the drawer's bonus, or ""
+// if nobody should.
+func calculateRoundScores(gs *GameState, drawerId string) map[string]int {
 	roundScores := make(map[string]int)
-	if gs.CurrentDrawerIdx < 0 || gs.CurrentDrawerIdx >= len(gs.Players) {
-		log.Printf("calculateRoundScores: Invalid drawer index %d, cannot calculate drawer bonus.", gs.CurrentDrawerIdx)
-	} else {
-		drawer := gs.Players[gs.CurrentDrawerIdx]
-		roundScores[drawer.Id] = 0
+	if drawerId != "" {
+		roundScores[drawerId] = 0
 	}
 
 	numGuessers := len(gs.CorrectGuessTimes)
@@ -55,15 +55,14 @@ func calculateRoundScores(gs *GameState) map[string]int {
 
 	for playerID, guessTime := range gs.CorrectGuessTimes {
 		isFirst := playerID == firstGuesserID
-		roundScores[playerID] = calculateGuesserScoreAtTime(gs.TurnStartTime, guessTime, turnDuration, isFirst)
+		roundScores[playerID] = calculateGuesserScoreAtTime(gs.TurnStartTime, guessTime, gs.Settings.TurnDuration, isFirst, gs.hintsRevealedBy(guessTime))
 	}
 
-	if numGuessers > 0 && gs.CurrentDrawerIdx >= 0 && gs.CurrentDrawerIdx < len(gs.Players) {
-		drawer := gs.Players[gs.CurrentDrawerIdx]
+	if numGuessers > 0 && drawerId != "" {
 		if allGuessed {
-			roundScores[drawer.Id] += drawerFullBonus
+			roundScores[drawerId] += drawerFullBonus
 		} else {
-			roundScores[drawer.Id] += drawerPartialBonus
+			roundScores[drawerId] += drawerPartialBonus
 		}
 	}
 
@@ -0,0 +1,126 @@
+package game
+
+import (
+	"backend/messages"
+	"backend/moderation"
+	"fmt"
+	"time"
+)
+
+// GameSettings are the host-configurable knobs for a game, changeable while waiting in the lobby
+type GameSettings struct {
+	TotalRounds        int               // Number of rounds, each player draws once per round
+	TurnDuration       time.Duration     // How long the drawer has to draw
+	WordChoiceDuration time.Duration     // How long the drawer has to pick a word
+	TurnEndDelay       time.Duration     // How long the answer is shown before the next turn
+	WordChoiceCount    int               // How many words the drawer picks from
+	AllowCustomWords   bool              // Whether the drawer may draw a word of their own instead of one offered
+	AfkPolicy          AfkPolicy         // What happens to players who stop responding
+	VotePercent        int               // Share of voters who must vote yes to kick a player or skip a turn
+	ModerationAction   moderation.Action // What happens to names and chat containing blocked words
+}
+
+const (
+	minTotalRounds        = 1
+	maxTotalRounds        = 10
+	minTurnDuration       = 20 * time.Second
+	maxTurnDuration       = 240 * time.Second
+	minWordChoiceDuration = 5 * time.Second
+	maxWordChoiceDuration = 30 * time.Second
+	minTurnEndDelay       = 3 * time.Second
+	maxTurnEndDelay       = 15 * time.Second
+	minWordChoiceCount    = 1
+	maxWordChoiceCount    = 5
+	minVotePercent        = 51
+	maxVotePercent        = 100
+	defaultVotePercent    = 60
+)
+
+func DefaultGameSettings() GameSettings {
+	return GameSettings{
+		TotalRounds:        1,
+		TurnDuration:       59 * time.Second,
+		WordChoiceDuration: 10 * time.Second,
+		TurnEndDelay:       5 * time.Second,
+		WordChoiceCount:    3,
+		AfkPolicy:          AfkPolicyMark,
+		VotePercent:        defaultVotePercent,
+		ModerationAction:   moderation.ActionMask,
+	}
+}
+
+// Validate checks every setting is within the range the server is willing to run
+func (s GameSettings) Validate() error {
+	if s.TotalRounds < minTotalRounds || s.TotalRounds > maxTotalRounds {
+		return fmt.Errorf("rounds must be between %d and %d", minTotalRounds, maxTotalRounds)
+	}
+	if s.TurnDuration < minTurnDuration || s.TurnDuration > maxTurnDuration {
+		return fmt.Errorf("turn time must be between %d and %d seconds", int(minTurnDuration.Seconds()), int(maxTurnDuration.Seconds()))
+	}
+	if s.WordChoiceDuration < minWordChoiceDuration || s.WordChoiceDuration > maxWordChoiceDuration {
+		return fmt.Errorf("word choice time must be between %d and %d seconds", int(minWordChoiceDuration.Seconds()), int(maxWordChoiceDuration.Seconds()))
+	}
+	if s.TurnEndDelay < minTurnEndDelay || s.TurnEndDelay > maxTurnEndDelay {
+		return fmt.Errorf("turn end delay must be between %d and %d seconds", int(minTurnEndDelay.Seconds()), int(maxTurnEndDelay.Seconds()))
+	}
+	if s.WordChoiceCount < minWordChoiceCount || s.WordChoiceCount > maxWordChoiceCount {
+		return fmt.Errorf("word choice count must be between %d and %d", minWordChoiceCount, maxWordChoiceCount)
+	}
+	if s.AfkPolicy != AfkPolicyMark && s.AfkPolicy != AfkPolicyRemove {
+		return fmt.Errorf("afk policy must be %q or %q", AfkPolicyMark, AfkPolicyRemove)
+	}
+	if s.VotePercent < minVotePercent || s.VotePercent > maxVotePercent {
+		return fmt.Errorf("vote percent must be between %d and %d", minVotePercent, maxVotePercent)
+	}
+	if err := s.ModerationAction.Validate(); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (s GameSettings) toPayload() messages.GameSettingsPayload {
+	return messages.GameSettingsPayload{
+		TotalRounds:      s.TotalRounds,
+		TurnDurationSecs: int(s.TurnDuration / time.Second),
+		WordChoiceSecs:   int(s.WordChoiceDuration / time.Second),
+		TurnEndDelaySecs: int(s.TurnEndDelay / time.Second),
+		WordChoiceCount:  s.WordChoiceCount,
+		AllowCustomWords: s.AllowCustomWords,
+		AfkPolicy:        string(s.AfkPolicy),
+		VotePercent:      s.VotePercent,
+		Moderation:       string(s.ModerationAction),
+	}
+}
+
+func settingsFromPayload(p messages.GameSettingsPayload) GameSettings {
+	afkPolicy := AfkPolicy(p.AfkPolicy)
+	if afkPolicy == "" {
+		afkPolicy = AfkPolicyMark
+	}
+	votePercent := p.VotePercent
+	if votePercent == 0 {
+		votePercent = defaultVotePercent
+	}
+	moderationAction := moderation.Action(p.Moderation)
+	if moderationAction == "" {
+		moderationAction = moderation.ActionMask
+	}
+
+	return GameSettings{
+		TotalRounds:        p.TotalRounds,
+		TurnDuration:       time.Duration(p.TurnDurationSecs) * time.Second,
+		WordChoiceDuration: time.Duration(p.WordChoiceSecs) * time.Second,
+		TurnEndDelay:       time.Duration(p.TurnEndDelaySecs) * time.Second,
+		WordChoiceCount:    p.WordChoiceCount,
+		AllowCustomWords:   p.AllowCustomWords,
+		AfkPolicy:          afkPolicy,
+		VotePercent:        votePercent,
+		ModerationAction:   moderationAction,
+	}
+}
+
+func (g *GameState) broadcastSettings() {
+	payload := g.Settings.toPayload()
+	msg := messages.Message{Type: messages.SettingsUpdateResponse, Payload: messages.MustMarshal(payload)}
+	g.Broadcaster.Broadcast(msg)
+}
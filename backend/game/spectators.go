@@ -0,0 +1,135 @@
+package game
+
+import (
+	"backend/messages"
+	"encoding/json"
+	"log"
+	"slices"
+)
+
+// Spectators watch the game but don't guess, don't draw and aren't waited on. Anyone joining while a game is
+// running spectates until the next round starts, when they're promoted into the game.
+
+func (g *GameState) spectatorIndex(id string) int {
+	for i, s := range g.Spectators {
+		if s.Id == id {
+			return i
+		}
+	}
+	return -1
+}
+
+func (g *GameState) isSpectator(p *Player) bool {
+	return g.spectatorIndex(p.Id) >= 0
+}
+
+// addSpectator adds a new spectator, who will be promoted at the next round boundary if they want to play
+func (g *GameState) addSpectator(player *Player, wantsToPlay bool) {
+	player.waitingToPlay = wantsToPlay
+	g.Spectators = append(g.Spectators, player)
+	log.Printf("GameState: Player %s (%s) is spectating (waiting to play: %t). Total spectators: %d", player.Id, player.Name, wantsToPlay, len(g.Spectators))
+}
+
+func (g *GameState) removeSpectator(player *Player) {
+	i := g.spectatorIndex(player.Id)
+	if i < 0 {
+		return
+	}
+	g.Spectators = slices.Delete(g.Spectators, i, i+1)
+	log.Printf("GameState: Spectator %s (%s) removed. Remaining spectators: %d", player.Id, player.Name, len(g.Spectators))
+}
+
+// promoteSpectators moves every spectator waiting to play into the game. They go on the end of Players so
+// the drawer index and drawing order aren't disturbed.
+func (g *GameState) promoteSpectators() {
+	remaining := make([]*Player, 0, len(g.Spectators))
+	promoted := 0
+	for _, s := range g.Spectators {
+		if !s.waitingToPlay {
+			remaining = append(remaining, s)
+			continue
+		}
+
+		s.waitingToPlay = false
+		g.Players = append(g.Players, s)
+		promoted++
+		log.Printf("GameState: Spectator %s (%s) joined the game.", s.Id, s.Name)
+		if g.HostId == "" {
+			g.HostId = s.Id
+		}
+	}
+
+	if promoted == 0 {
+		return
+	}
+	g.Spectators = remaining
+	g.broadcastPlayerUpdate()
+}
+
+// makeSpectator moves a player out of the game, only safe to use when a game isn't running
+func (g *GameState) makeSpectator(player *Player) {
+	i := g.playerIndex(player.Id)
+	if i < 0 {
+		return
+	}
+
+	g.Players = slices.Delete(g.Players, i, i+1)
+	g.addSpectator(player, false)
+
+	if g.HostId == player.Id {
+		g.HostId = ""
+		if len(g.Players) > 0 {
+			g.HostId = g.Players[0].Id
+		}
+	}
+	g.broadcastPlayerUpdate()
+}
+
+// watchersExcept is everyone that should see the game as a guesser does, i.e. every player except the one
+// at drawerIdx, plus the spectators
+func (g *GameState) watchersExcept(drawerIdx int) []*Player {
+	watchers := make([]*Player, 0, len(g.Players)+len(g.Spectators))
+	for i, p := range g.Players {
+		if p != nil && i != drawerIdx {
+			watchers = append(watchers, p)
+		}
+	}
+	return append(watchers, g.Spectators...)
+}
+
+func (g *GameState) getSpectatorInfoList() []messages.PlayerInfo {
+	infoList := make([]messages.PlayerInfo, 0, len(g.Spectators))
+	for _, s := range g.Spectators {
+		infoList = append(infoList, messages.PlayerInfo{
+			ID:            s.Id,
+			Name:          s.Name,
+			IsSpectator:   true,
+			WaitingToPlay: s.waitingToPlay,
+		})
+	}
+	return infoList
+}
+
+// handleSpectatorMessage deals with messages from spectators, which never reach the phase handlers
+func (g *Game) handleSpectatorMessage(player *Player, msg messages.Message) {
+	state := g.GameState
+
+	switch msg.Type {
+	case messages.ClientSetSpectating:
+		var payload messages.SetSpectatingPayload
+		if err := json.Unmarshal(msg.Payload, &payload); err != nil {
+			player.SendError("Invalid spectating format.")
+			return
+		}
+
+		i := state.spectatorIndex(player.Id)
+		state.Spectators[i].waitingToPlay = !payload.Spectating
+		if !state.IsActive && g.GameHandler.Phase() == GamePhaseWaitingInLobby {
+			state.promoteSpectators()
+		} else {
+			state.broadcastPlayerUpdate()
+		}
+	default:
+		log.Printf("GameState: Ignoring message type %s from spectator %s.", msg.Type, player.Name)
+	}
+}
@@ -2,24 +2,30 @@ package game
 
 import (
 	"backend/messages"
+	"backend/moderation"
+	"backend/words"
 	"encoding/json"
 	"log"
 	"sync"
 	"time"
+	"unicode/utf8"
 )
 
 type Broadcaster interface {
 	Broadcast(m messages.Message)
 	BroadcastToPlayers(message messages.Message, players []*Player)
+	BroadcastDrawEvent(event messages.DrawEventPayload, players []*Player)
 }
 
 // GameState represents the single, shared game session.
 // TODO: move a bunch of this state into the phases.
 type GameState struct {
 	Players           []*Player
+	Spectators        []*Player // Watching rather than playing, see spectators.go
 	HostId            string
 	CurrentDrawerIdx  int                  // Index in Players slice of the current drawer (-1 if no game)
 	Word              string               // The secret word for the current turn
+	Hints             []hint               // Letters of the word revealed to guessers so far this turn
 	CorrectGuessTimes map[string]time.Time // player ID -> time they guessed correctly
 	TurnStartTime     time.Time            // When the current turn (drawing phase) started
 	Broadcaster       Broadcaster
@@ -28,25 +34,42 @@ type GameState struct {
 
 	timerForTimeout *time.Timer
 	turnEndTime     time.Time
-	
-	TotalRounds                  int
+
+	Settings                     GameSettings
 	CurrentRound                 int
 	PlayersWhoHaveDrawnThisRound []string
+
+	Words  *words.Deck // Words from the room's chosen packs, dealt without repeats
+	Canvas *Canvas     // The current turn's drawing
+
+	disconnected map[string]*time.Timer // player ID -> timer removing them if they don't reconnect
+	afk          map[string]bool        // IDs of players who stopped responding, see afk.go
+	ackTimeout   time.Duration
+	admission    Admission
+	pending      []*Player // New players waiting for the host to let them in, see admission.go
+	bannedIds    map[string]bool
+	bannedIPs    map[string]bool
+
+	vote          *vote                // The vote going on, if there is one, see votes.go
+	voteCooldowns map[string]time.Time // Player ID -> when they can next start a vote
+
+	blocklist *moderation.Blocklist
 }
 
 func (g *GameState) broadcastPlayerUpdate() {
 	payload := messages.PlayerUpdatePayload{
-		Players: g.getPlayerInfoList(), // Assumes lock held
-		HostID:  g.HostId,
+		Players:    g.getPlayerInfoList(), // Assumes lock held
+		Spectators: g.getSpectatorInfoList(),
+		HostID:     g.HostId,
 	}
 	msg := messages.Message{Type: messages.PlayerUpdateResponse, Payload: json.RawMessage(messages.MustMarshal(payload))}
-	go g.Broadcaster.Broadcast(msg)
+	g.Broadcaster.Broadcast(msg)
 }
 
 func (g *GameState) BroadcastSystemMessage(message string) {
 	payload := messages.ChatPayload{SenderName: "System", Message: message, IsSystem: true}
 	msg := messages.Message{Type: messages.ChatResponse, Payload: json.RawMessage(messages.MustMarshal(payload))}
-	go g.Broadcaster.Broadcast(msg)
+	g.Broadcaster.Broadcast(msg)
 }
 
 func (g *GameState) getPlayerInfoList() []messages.PlayerInfo {
@@ -60,6 +83,8 @@ func (g *GameState) getPlayerInfoList() []messages.PlayerInfo {
 				Score:               p.Score,
 				IsHost:              p.Id == g.HostId,
 				HasGuessedCorrectly: hasGuessedCorrectly,
+				Disconnected:        g.disconnected[p.Id] != nil,
+				IsAfk:               g.afk[p.Id],
 			})
 		} else {
 			log.Printf("GameState Error: Found nil player in g.Players during getPlayerInfoList")
@@ -68,6 +93,16 @@ func (g *GameState) getPlayerInfoList() []messages.PlayerInfo {
 	return infoList
 }
 
+// playerIndex returns the index of the player with the ID in Players, or -1 if they aren't in the game
+func (g *GameState) playerIndex(id string) int {
+	for i, p := range g.Players {
+		if p != nil && p.Id == id {
+			return i
+		}
+	}
+	return -1
+}
+
 func (g *GameState) isDrawer(p *Player) bool {
 	if !g.IsActive {
 		return false
@@ -80,10 +115,6 @@ func (g *GameState) isDrawer(p *Player) bool {
 	return g.Players[g.CurrentDrawerIdx].Id == p.Id
 }
 
-var words = []string{"apple", "banana", "cloud", "house", "tree", "computer", "go", "svelte", "network", "game", "player", "draw", "timer", "guess", "score", "host", "lobby", "react"}
-
-var turnDuration = 59 * time.Second
-
 const (
 	minPlayersToStart = 2
 )
@@ -94,21 +125,39 @@ func (g *Game) sendGameInfo(player *Player) {
 	payload := messages.GameInfoPayload{
 		GamePhase:    g.GameHandler.Phase().String(),
 		YourID:       player.Id,
+		ResumeToken:  player.ResumeToken,
 		Players:      state.getPlayerInfoList(),
+		Spectators:   state.getSpectatorInfoList(),
+		IsSpectator:  state.isSpectator(player),
 		HostID:       state.HostId,
 		IsGameActive: state.IsActive,
+		Settings:     state.Settings.toPayload(),
 	}
 
 	if state.IsActive && state.CurrentDrawerIdx >= 0 && state.CurrentDrawerIdx < len(state.Players) {
 		payload.CurrentDrawerID = state.Players[state.CurrentDrawerIdx].Id
-		payload.WordLength = len(state.Word)
+		payload.WordLength = utf8.RuneCountInString(state.Word)
+		mask := buildWordMask(state.Word)
+		payload.Mask = &mask
 		payload.TurnEndTime = state.turnEndTime.UnixMilli()
 		if player.Id == payload.CurrentDrawerID {
 			payload.Word = state.Word
+		} else {
+			payload.RevealedLetters = state.revealedLetters()
 		}
 	}
+	// Anyone arriving mid-turn needs what's been drawn so far
+	var snapshot *messages.CanvasSnapshotPayload
+	if g.GameHandler.Phase() == GamePhaseRoundInProgress && !state.Canvas.IsEmpty() {
+		s := state.Canvas.Snapshot()
+		snapshot = &s
+	}
+
 	log.Printf("GameState: Sending game info to player %s (%s). Active: %t, Host: %s", player.Id, player.Name, payload.IsGameActive, state.HostId)
-	go player.SendMessage(messages.GameInfoResponse, payload)
+	player.SendMessage(messages.GameInfoResponse, payload)
+	if snapshot != nil {
+		player.SendMessage(messages.CanvasSnapshotResponse, snapshot)
+	}
 }
 
 func (g *GameState) HandleStartGame(sender *Player) {
@@ -134,27 +183,57 @@ func (g *GameState) HandleStartGame(sender *Player) {
 	g.PlayersWhoHaveDrawnThisRound = make([]string, 0)
 }
 
+// resetGame clears everything about the last game so the same players can start a new one with the same
+// settings. Spectators waiting to play join in.
+func (g *GameState) resetGame() {
+	g.IsActive = false
+	g.CurrentRound = 0
+	g.PlayersWhoHaveDrawnThisRound = make([]string, 0)
+	g.CurrentDrawerIdx = -1
+	g.Word = ""
+	g.Hints = nil
+	g.CorrectGuessTimes = make(map[string]time.Time)
+	g.Canvas.Reset()
+	for _, p := range g.Players {
+		p.Score = 0
+	}
+	g.promoteSpectators()
+	g.broadcastPlayerUpdate()
+}
+
 func (g *GameState) checkAllGuessed() bool {
 	totalPlayers := len(g.Players)
 
 	if !g.IsActive || totalPlayers < minPlayersToStart || g.CurrentDrawerIdx < 0 || g.CurrentDrawerIdx >= len(g.Players) {
 		return false
 	}
+	// AFK players aren't waited on, unless they've guessed anyway
 	correctCount := 0
+	requiredCorrect := 0
 	for i, p := range g.Players {
 		if i != g.CurrentDrawerIdx {
-			if _, guessed := g.CorrectGuessTimes[p.Id]; guessed {
+			_, guessed := g.CorrectGuessTimes[p.Id]
+			if guessed {
 				correctCount++
 			}
+			if guessed || !g.afk[p.Id] {
+				requiredCorrect++
+			}
 		}
 	}
 
-	requiredCorrect := totalPlayers - 1
-	return correctCount == requiredCorrect
+	return requiredCorrect > 0 && correctCount == requiredCorrect
 }
 
 func (g *GameState) BroadcastChatMessage(senderName, message string) {
 	payload := messages.ChatPayload{SenderName: senderName, Message: message, IsSystem: false}
 	msg := messages.Message{Type: messages.ChatResponse, Payload: json.RawMessage(messages.MustMarshal(payload))}
-	go g.Broadcaster.Broadcast(msg)
+	g.Broadcaster.Broadcast(msg)
+}
+
+// BroadcastGuessMessage shows everyone a wrong guess
+func (g *GameState) BroadcastGuessMessage(senderName, guess string) {
+	payload := messages.ChatPayload{SenderName: senderName, Message: guess, IsGuess: true}
+	msg := messages.Message{Type: messages.ChatResponse, Payload: json.RawMessage(messages.MustMarshal(payload))}
+	g.Broadcaster.Broadcast(msg)
 }
@@ -0,0 +1,226 @@
+package game
+
+import (
+	"backend/messages"
+	"encoding/json"
+	"log"
+	"time"
+)
+
+type VoteKind string
+
+const (
+	VoteKick VoteKind = "kick" // Remove a player from the room
+	VoteSkip VoteKind = "skip" // End the current turn early, without points for the drawer
+)
+
+// Fewest players, not counting the target, who must have a say for a vote to go ahead. Otherwise the vote
+// would pass on the starter's yes alone.
+const minVoters = 2
+
+// vote is a vote any player can start to kick someone or skip the turn, so the room doesn't have to rely
+// on the host. Only one runs at a time.
+type vote struct {
+	kind      VoteKind
+	target    *Player          // Who is being kicked, or the drawer whose turn is being skipped
+	turn      GamePhaseHandler // The turn being skipped
+	startedBy *Player
+	ballots   map[string]bool // Player ID -> voted yes
+	endTime   time.Time
+	timer     *time.Timer
+}
+
+// eligibleVoters is everyone who gets a say: players still connected and active, other than the target
+func (g *GameState) eligibleVoters(v *vote) []*Player {
+	voters := make([]*Player, 0, len(g.Players))
+	for _, p := range g.Players {
+		if p.Id != v.target.Id && g.disconnected[p.Id] == nil && !g.afk[p.Id] {
+			voters = append(voters, p)
+		}
+	}
+	return voters
+}
+
+// votesNeeded is how many yes votes pass the vote out of the voters given. It's never less than minVoters, so
+// a vote left with too few voters fails rather than passing on one yes.
+func (g *GameState) votesNeeded(voters int) int {
+	return max((voters*g.Settings.VotePercent+99)/100, minVoters)
+}
+
+func (g *Game) handleStartVote(player *Player, msg messages.Message) {
+	state := g.GameState
+
+	var payload messages.StartVotePayload
+	if err := json.Unmarshal(msg.Payload, &payload); err != nil {
+		player.SendError("Invalid vote format.")
+		return
+	}
+
+	if state.playerIndex(player.Id) < 0 {
+		player.SendError("Only players can start a vote.")
+		return
+	}
+	if state.vote != nil {
+		player.SendError("There's already a vote going.")
+		return
+	}
+	if until, ok := state.voteCooldowns[player.Id]; ok && time.Now().Before(until) {
+		player.SendError("You need to wait before starting another vote.")
+		return
+	}
+
+	v := &vote{
+		kind:      VoteKind(payload.Kind),
+		startedBy: player,
+		ballots:   make(map[string]bool),
+	}
+
+	switch v.kind {
+	case VoteKick:
+		i := state.playerIndex(payload.TargetID)
+		if i < 0 {
+			player.SendError("That player isn't in the game.")
+			return
+		}
+		v.target = state.Players[i]
+	case VoteSkip:
+		if _, ok := g.GameHandler.(*RoundInProgressHandler); !ok || state.CurrentDrawerIdx < 0 || state.CurrentDrawerIdx >= len(state.Players) {
+			player.SendError("There's no turn to skip.")
+			return
+		}
+		v.target = state.Players[state.CurrentDrawerIdx]
+		v.turn = g.GameHandler
+	default:
+		player.SendError("Unknown vote kind.")
+		return
+	}
+
+	if v.target.Id == player.Id {
+		player.SendError("You can't start a vote against yourself.")
+		return
+	}
+	if len(state.eligibleVoters(v)) < minVoters {
+		player.SendError("There aren't enough players around to vote on that.")
+		return
+	}
+
+	state.voteCooldowns[player.Id] = time.Now().Add(g.Config.VoteCooldown)
+	state.vote = v
+	v.ballots[player.Id] = true
+	v.endTime = time.Now().Add(g.Config.VoteDuration)
+	v.timer = time.AfterFunc(g.Config.VoteDuration, func() {
+		state.mu.Lock()
+		defer state.mu.Unlock()
+
+		if state.vote == v {
+			g.recoverEvent("vote timing out", func() {
+				g.endVote(messages.VoteResultFailed)
+			})
+		}
+	})
+
+	log.Printf("GameState: Player %s (%s) started a vote to %s %s (%s).", player.Id, player.Name, v.kind, v.target.Id, v.target.Name)
+	g.tallyVote()
+}
+
+func (g *Game) handleCastVote(player *Player, msg messages.Message) {
+	state := g.GameState
+
+	var payload messages.CastVotePayload
+	if err := json.Unmarshal(msg.Payload, &payload); err != nil {
+		player.SendError("Invalid vote format.")
+		return
+	}
+
+	v := state.vote
+	if v == nil {
+		player.SendError("There's no vote going.")
+		return
+	}
+	if player.Id == v.target.Id || state.playerIndex(player.Id) < 0 {
+		player.SendError("You can't vote on this.")
+		return
+	}
+
+	v.ballots[player.Id] = payload.Yes
+	g.tallyVote()
+}
+
+// tallyVote ends the vote as soon as the result is certain, otherwise lets everyone know where it stands.
+// Assumes the lock is held.
+func (g *Game) tallyVote() {
+	state := g.GameState
+	v := state.vote
+	if v == nil {
+		return
+	}
+
+	// The vote is moot if who or what it was about has gone
+	if state.playerIndex(v.target.Id) < 0 || (v.kind == VoteSkip && g.GameHandler != v.turn) {
+		g.endVote(messages.VoteResultCancelled)
+		return
+	}
+
+	yes, undecided, needed := state.countVotes(v)
+	switch {
+	case yes >= needed:
+		g.endVote(messages.VoteResultPassed)
+	case yes+undecided < needed:
+		g.endVote(messages.VoteResultFailed)
+	default:
+		state.broadcastVote(v, "")
+	}
+}
+
+// countVotes counts the yes votes from current voters, and how many of them haven't voted yet
+func (g *GameState) countVotes(v *vote) (yes, undecided, needed int) {
+	voters := g.eligibleVoters(v)
+	for _, p := range voters {
+		if votedYes, voted := v.ballots[p.Id]; !voted {
+			undecided++
+		} else if votedYes {
+			yes++
+		}
+	}
+	return yes, undecided, g.votesNeeded(len(voters))
+}
+
+// endVote finishes the current vote, carrying it out if it passed. Assumes the lock is held.
+func (g *Game) endVote(result string) {
+	state := g.GameState
+	v := state.vote
+	state.vote = nil
+	v.timer.Stop()
+
+	log.Printf("GameState: Vote to %s %s (%s) %s.", v.kind, v.target.Id, v.target.Name, result)
+	state.broadcastVote(v, result)
+	if result != messages.VoteResultPassed {
+		return
+	}
+
+	switch v.kind {
+	case VoteKick:
+		state.BroadcastSystemMessage(v.target.Name + " was voted out.")
+		g.expel(v.target, messages.ErrorCodeKicked, "You were voted out of the room.")
+	case VoteSkip:
+		state.BroadcastSystemMessage("The turn was skipped by vote.")
+		g.updateHandler(ackPhaseTransitionTo(&RoundFinishedHandler{Skipped: true}))
+	}
+}
+
+func (g *GameState) broadcastVote(v *vote, result string) {
+	yes, undecided, needed := g.countVotes(v)
+	payload := messages.VotePayload{
+		Kind:       string(v.kind),
+		TargetID:   v.target.Id,
+		TargetName: v.target.Name,
+		StartedBy:  v.startedBy.Id,
+		Yes:        yes,
+		No:         len(g.eligibleVoters(v)) - yes - undecided,
+		Needed:     needed,
+		EndTime:    v.endTime.UnixMilli(),
+		Result:     result,
+	}
+	msg := messages.Message{Type: messages.VoteUpdateResponse, Payload: json.RawMessage(messages.MustMarshal(payload))}
+	g.Broadcaster.Broadcast(msg)
+}
@@ -0,0 +1,39 @@
+package game
+
+import (
+	"backend/messages"
+	"unicode"
+)
+
+// isGuessableRune reports whether a character of the word is hidden from guessers. Everything else
+// (spaces, hyphens, punctuation) is shown to them as-is.
+func isGuessableRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+// buildWordMask describes the shape of the word without giving away its letters. Words are separated by
+// whitespace, and each word's length only counts the characters that need guessing.
+func buildWordMask(word string) messages.WordMask {
+	mask := messages.WordMask{
+		WordLengths: make([]int, 0, 1),
+		FixedChars:  make([]messages.FixedChar, 0),
+	}
+
+	inWord := false
+	for i, r := range []rune(word) {
+		if unicode.IsSpace(r) {
+			inWord = false
+		} else if !inWord {
+			inWord = true
+			mask.WordLengths = append(mask.WordLengths, 0)
+		}
+
+		if isGuessableRune(r) {
+			mask.WordLengths[len(mask.WordLengths)-1]++
+		} else {
+			mask.FixedChars = append(mask.FixedChars, messages.FixedChar{Index: i, Char: string(r)})
+		}
+	}
+
+	return mask
+}
@@ -2,33 +2,109 @@ package main
 
 import (
 	"backend/api"
+	"backend/game"
+	"backend/moderation"
 	"backend/room"
+	"backend/session"
+	"backend/words"
+	"context"
+	"expvar"
 	"log"
 	"net/http"
+	"os"
+	"strconv"
+	"time"
 
 	"github.com/gorilla/mux"
 )
 
 func main() {
-	rm := room.NewRoomManager()
-	go rm.Run()
+	wordLibrary, err := words.NewDefaultLibrary(os.Getenv("WORD_PACKS_DIR"))
+	if err != nil {
+		log.Fatal("Failed to load word packs: ", err)
+	}
+
+	blocklist, err := moderation.LoadBlocklist(os.Getenv("BLOCKLIST_FILE"))
+	if err != nil {
+		log.Fatal("Failed to load blocklist: ", err)
+	}
+
+	gameConfig := game.DefaultConfig()
+	gameConfig.Blocklist = blocklist
+	gameConfig.ReconnectGracePeriod = secondsFromEnv("RECONNECT_GRACE_SECONDS", gameConfig.ReconnectGracePeriod)
+	gameConfig.AckTimeout = secondsFromEnv("ACK_TIMEOUT_SECONDS", gameConfig.AckTimeout)
+	gameConfig.Connection.PingInterval = secondsFromEnv("PING_INTERVAL_SECONDS", gameConfig.Connection.PingInterval)
+	gameConfig.Connection.PongWait = secondsFromEnv("PONG_WAIT_SECONDS", gameConfig.Connection.PongWait)
+	if gameConfig.Connection.PingInterval <= 0 || gameConfig.Connection.PongWait <= gameConfig.Connection.PingInterval {
+		log.Fatal("PING_INTERVAL_SECONDS must be positive and less than PONG_WAIT_SECONDS")
+	}
+
+	tokens := session.NewRandomSigner()
+	if secret := os.Getenv("SESSION_SECRET"); secret != "" {
+		tokens = session.NewSigner([]byte(secret))
+	}
+
+	roomConfig := room.DefaultManagerConfig()
+	roomConfig.IdleTimeout = secondsFromEnv("ROOM_IDLE_SECONDS", roomConfig.IdleTimeout)
+	if maxRooms := os.Getenv("MAX_ROOMS"); maxRooms != "" {
+		roomConfig.MaxRooms, err = strconv.Atoi(maxRooms)
+		if err != nil || roomConfig.MaxRooms < 0 {
+			log.Fatalf("Invalid MAX_ROOMS: %s", maxRooms)
+		}
+	}
+
+	rm := room.NewRoomManager(wordLibrary, gameConfig, roomConfig)
+	go rm.Run(context.Background())
+	expvar.Publish("outbound_queue_depth_by_room", expvar.Func(func() any { return rm.QueueDepths() }))
 
 	staticDir := "./public"
 	fileServer := http.FileServer(http.Dir(staticDir))
 
 	router := mux.NewRouter()
 
-	router.HandleFunc("/ws/{roomId}", func(w http.ResponseWriter, r *http.Request) { api.ServeWS(rm, w, r) })
+	router.HandleFunc("/ws/{roomId}", func(w http.ResponseWriter, r *http.Request) { api.ServeWS(rm, tokens, w, r) })
 	router.PathPrefix("/assets/").Handler(fileServer)
 	router.Path("/").HandlerFunc(func(w http.ResponseWriter, r *http.Request) { api.HandleIndex(staticDir, fileServer, w, r) })
+	router.Path("/word-packs").Methods(http.MethodGet).HandlerFunc(func(w http.ResponseWriter, r *http.Request) { api.HandleGetWordPacks(rm, w, r) })
 	router.PathPrefix("/create-room").Methods(http.MethodPost).HandlerFunc(func(w http.ResponseWriter, r *http.Request) { api.HandleCreateRoom(rm, w, r) })
 	router.PathPrefix("/{roomId}").Methods(http.MethodGet).HandlerFunc(func(w http.ResponseWriter, r *http.Request) { api.HandleGetRoom(rm, w, r) })
 
+	// Metrics are only served on their own listener, which should be somewhere the internet can't reach
+	if debugAddr := os.Getenv("DEBUG_ADDR"); debugAddr != "" {
+		go serveDebug(debugAddr)
+	}
+
 	port := "8080"
 	log.Printf("Server starting on http://localhost:%s", port)
 	server := &http.Server{Addr: ":" + port, Handler: router}
-	err := server.ListenAndServe()
+	err = server.ListenAndServe()
 	if err != nil {
 		log.Fatal("ListenAndServe Error: ", err)
 	}
 }
+
+// serveDebug serves expvar metrics on addr, e.g. localhost:6060
+func serveDebug(addr string) {
+	debugRouter := http.NewServeMux()
+	debugRouter.Handle("/debug/vars", expvar.Handler())
+
+	log.Printf("Debug server starting on http://%s/debug/vars", addr)
+	err := http.ListenAndServe(addr, debugRouter)
+	if err != nil {
+		log.Fatal("Debug ListenAndServe Error: ", err)
+	}
+}
+
+// secondsFromEnv reads a whole number of seconds from an environment variable, using def if it isn't set
+func secondsFromEnv(name string, def time.Duration) time.Duration {
+	value := os.Getenv(name)
+	if value == "" {
+		return def
+	}
+
+	seconds, err := strconv.Atoi(value)
+	if err != nil || seconds < 0 {
+		log.Fatalf("Invalid %s: %s", name, value)
+	}
+	return time.Duration(seconds) * time.Second
+}
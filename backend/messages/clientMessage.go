@@ -7,6 +7,16 @@ const (
 	ClientStartGame       = "startGame"
 	ClientSelectRoundWord = "selectRoundWord"
 	ClientPhaseChangeAck  = "phaseChangeAck"
+	ClientUpdateSettings  = "updateSettings"
+	ClientSetSpectating   = "setSpectating"
+	ClientReturnToLobby   = "returnToLobby" // Host only, from GameOver or Error, to play again in the same room
+	ClientRespondToJoin   = "respondToJoin" // Host only, lets in or turns away a player knocking to join
+	ClientKickPlayer      = "kickPlayer"    // Host only
+	ClientBanPlayer       = "banPlayer"     // Host only
+	ClientTransferHost    = "transferHost"  // Host only
+	ClientStartVote       = "startVote"
+	ClientChat            = "chat"
+	ClientCastVote        = "castVote"
 )
 
 type SetNamePayload struct {
@@ -17,12 +27,37 @@ type GuessPayload struct {
 	Guess string `json:"guess"`
 }
 
+// ChatMessagePayload is chat, which works in every phase. While a turn is going, chat from players who
+// haven't guessed the word counts as a guess.
+type ChatMessagePayload struct {
+	Message string `json:"message"`
+}
+
 type SelectRoundWordPayload struct {
 	Word string `json:"word"`
 }
 
+// SetSpectatingPayload switches between watching and playing. Players can only start spectating in the lobby,
+// spectators asking to play join at the next round.
+type SetSpectatingPayload struct {
+	Spectating bool `json:"spectating"`
+}
+
+const (
+	DrawEventStart = "start" // Begins a stroke at x, y
+	DrawEventDraw  = "draw"  // Continues the current stroke to x, y
+	DrawEventEnd   = "end"   // Finishes the current stroke
+	DrawEventFill  = "fill"  // Flood fills the area around x, y with color
+	DrawEventClear = "clear" // Wipes the canvas, can be undone
+	DrawEventUndo  = "undo"  // Undoes the last stroke, fill or clear
+	DrawEventRedo  = "redo"  // Redoes the last undone operation
+)
+
+// DrawEventPayload is sent by the drawer and forwarded to everyone else. For undo and redo the server fills in
+// the stroke ID of the operation affected.
 type DrawEventPayload struct {
 	EventType string  `json:"eventType"`
+	StrokeID  string  `json:"strokeId,omitempty"` // Set by the client on start, fill and clear, or given one by the server
 	X         float64 `json:"x"`
 	Y         float64 `json:"y"`
 	Color     string  `json:"color,omitempty"`
@@ -30,3 +65,33 @@ type DrawEventPayload struct {
 }
 
 // StartGamePayload: No payload needed
+
+// UpdateSettingsPayload: uses GameSettingsPayload, the host sends the full set of settings
+
+// RespondToJoinPayload is the host's answer to a joinRequest
+type RespondToJoinPayload struct {
+	PlayerID string `json:"playerId"`
+	Admit    bool   `json:"admit"`
+}
+
+// TargetPlayerPayload names the player a host action applies to
+type TargetPlayerPayload struct {
+	PlayerID string `json:"playerId"`
+}
+
+// BanPlayerPayload bans a player for the rest of the room's life. ByIP also bans anyone else connecting from
+// their address.
+type BanPlayerPayload struct {
+	PlayerID string `json:"playerId"`
+	ByIP     bool   `json:"byIp,omitempty"`
+}
+
+// StartVotePayload starts a vote to kick a player or skip the current turn. TargetID is only used by kicks.
+type StartVotePayload struct {
+	Kind     string `json:"kind"` // "kick" or "skip"
+	TargetID string `json:"targetId,omitempty"`
+}
+
+type CastVotePayload struct {
+	Yes bool `json:"yes"`
+}
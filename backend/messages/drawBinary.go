@@ -0,0 +1,262 @@
+package messages
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Draw events can be sent as WebSocket binary frames instead of JSON by connections that negotiate the
+// DrawBinarySubprotocol. A frame batches several events:
+//
+//	frame := version:byte count:uvarint event*
+//	event := type:byte fields
+//
+// Fields by event type, where strings are a uvarint length then UTF-8 bytes:
+//
+//	start: strokeId:string color width:uvarint x:varint y:varint
+//	draw:  dx:varint dy:varint
+//	fill:  strokeId:string color x:varint y:varint
+//	end, clear, undo, redo: strokeId:string
+//
+// Coordinates are in 1/DrawCoordScale pixels. start and fill give an absolute position, and each draw is
+// relative to the previous point in the frame (or 0, 0 at the start of a frame). Line widths are in
+// 1/DrawLineWidthScale pixels. A color is an index into DrawPalette, or drawColorRGB followed by three bytes
+// for anything else.
+const DrawBinarySubprotocol = "flamingo.draw.v1"
+
+const (
+	DrawCoordScale     = 4
+	DrawLineWidthScale = 2
+
+	drawFrameVersion    = 1
+	maxDrawFrameEvents  = 4096
+	maxDrawStrokeIdSize = 64
+
+	drawColorNone = 0xFE
+	drawColorRGB  = 0xFF
+)
+
+// DrawPalette matches the colours offered by the whiteboard
+var DrawPalette = []string{
+	"#000000", "#FFFFFF", "#C1C1C1", "#505050",
+	"#EF120B", "#740A08", "#FF7700", "#C23900",
+	"#FFE404", "#E8A202", "#08C202", "#00461A",
+	"#00FF91", "#02569E", "#2220D3", "#0E0865",
+	"#A302BA", "#550069", "#DF69A7", "#883454",
+	"#FFAC8A", "#CC7C4D", "#A0522D", "#63300D",
+}
+
+var drawEventCodes = map[string]byte{
+	DrawEventStart: 1,
+	DrawEventDraw:  2,
+	DrawEventEnd:   3,
+	DrawEventFill:  4,
+	DrawEventClear: 5,
+	DrawEventUndo:  6,
+	DrawEventRedo:  7,
+}
+
+var drawEventTypes = func() map[byte]string {
+	types := make(map[byte]string, len(drawEventCodes))
+	for t, code := range drawEventCodes {
+		types[code] = t
+	}
+	return types
+}()
+
+var ErrInvalidDrawFrame = errors.New("invalid binary draw frame")
+
+// EncodeDrawFrame packs draw events into a single binary frame. Events of unknown types are skipped.
+func EncodeDrawFrame(events []DrawEventPayload) []byte {
+	buf := make([]byte, 0, 8+len(events)*4)
+	buf = append(buf, drawFrameVersion)
+
+	known := 0
+	for _, e := range events {
+		if _, ok := drawEventCodes[e.EventType]; ok {
+			known++
+		}
+	}
+	buf = binary.AppendUvarint(buf, uint64(known))
+
+	var cursorX, cursorY int64
+	for _, e := range events {
+		code, ok := drawEventCodes[e.EventType]
+		if !ok {
+			continue
+		}
+		buf = append(buf, code)
+
+		switch e.EventType {
+		case DrawEventStart, DrawEventFill:
+			buf = appendDrawString(buf, e.StrokeID)
+			buf = appendDrawColor(buf, e.Color)
+			if e.EventType == DrawEventStart {
+				buf = binary.AppendUvarint(buf, uint64(max(0, math.Round(e.LineWidth*DrawLineWidthScale))))
+			}
+			cursorX, cursorY = quantiseCoord(e.X), quantiseCoord(e.Y)
+			buf = binary.AppendVarint(buf, cursorX)
+			buf = binary.AppendVarint(buf, cursorY)
+		case DrawEventDraw:
+			x, y := quantiseCoord(e.X), quantiseCoord(e.Y)
+			buf = binary.AppendVarint(buf, x-cursorX)
+			buf = binary.AppendVarint(buf, y-cursorY)
+			cursorX, cursorY = x, y
+		default:
+			buf = appendDrawString(buf, e.StrokeID)
+		}
+	}
+
+	return buf
+}
+
+// DecodeDrawFrame unpacks a binary frame from a client into draw events
+func DecodeDrawFrame(data []byte) ([]DrawEventPayload, error) {
+	r := drawReader{data: data}
+
+	if version := r.byte(); version != drawFrameVersion {
+		return nil, fmt.Errorf("%w: unsupported version %d", ErrInvalidDrawFrame, version)
+	}
+	count := r.uvarint()
+	if count > maxDrawFrameEvents {
+		return nil, fmt.Errorf("%w: too many events (%d)", ErrInvalidDrawFrame, count)
+	}
+
+	events := make([]DrawEventPayload, 0, count)
+	var cursorX, cursorY int64
+	for i := uint64(0); i < count && r.err == nil; i++ {
+		eventType, ok := drawEventTypes[r.byte()]
+		if !ok {
+			return nil, fmt.Errorf("%w: unknown event type", ErrInvalidDrawFrame)
+		}
+		e := DrawEventPayload{EventType: eventType}
+
+		switch eventType {
+		case DrawEventStart, DrawEventFill:
+			e.StrokeID = r.string()
+			e.Color = r.color()
+			if eventType == DrawEventStart {
+				e.LineWidth = float64(r.uvarint()) / DrawLineWidthScale
+			}
+			cursorX, cursorY = r.varint(), r.varint()
+		case DrawEventDraw:
+			cursorX += r.varint()
+			cursorY += r.varint()
+		default:
+			e.StrokeID = r.string()
+		}
+
+		if eventType == DrawEventStart || eventType == DrawEventDraw || eventType == DrawEventFill {
+			e.X = float64(cursorX) / DrawCoordScale
+			e.Y = float64(cursorY) / DrawCoordScale
+		}
+		events = append(events, e)
+	}
+
+	if r.err != nil {
+		return nil, r.err
+	}
+	return events, nil
+}
+
+func quantiseCoord(v float64) int64 {
+	return int64(math.Round(v * DrawCoordScale))
+}
+
+func appendDrawString(buf []byte, s string) []byte {
+	if len(s) > maxDrawStrokeIdSize {
+		s = s[:maxDrawStrokeIdSize]
+	}
+	buf = binary.AppendUvarint(buf, uint64(len(s)))
+	return append(buf, s...)
+}
+
+func appendDrawColor(buf []byte, color string) []byte {
+	for i, c := range DrawPalette {
+		if strings.EqualFold(c, color) {
+			return append(buf, byte(i))
+		}
+	}
+
+	if len(color) == 7 && color[0] == '#' {
+		if rgb, err := strconv.ParseUint(color[1:], 16, 32); err == nil {
+			return append(buf, drawColorRGB, byte(rgb>>16), byte(rgb>>8), byte(rgb))
+		}
+	}
+	return append(buf, drawColorNone)
+}
+
+// drawReader reads a frame, remembering the first error so callers can check once at the end
+type drawReader struct {
+	data []byte
+	err  error
+}
+
+func (r *drawReader) fail() {
+	if r.err == nil {
+		r.err = fmt.Errorf("%w: truncated", ErrInvalidDrawFrame)
+	}
+	r.data = nil
+}
+
+func (r *drawReader) byte() byte {
+	if len(r.data) < 1 {
+		r.fail()
+		return 0
+	}
+	b := r.data[0]
+	r.data = r.data[1:]
+	return b
+}
+
+func (r *drawReader) uvarint() uint64 {
+	v, n := binary.Uvarint(r.data)
+	if n <= 0 {
+		r.fail()
+		return 0
+	}
+	r.data = r.data[n:]
+	return v
+}
+
+func (r *drawReader) varint() int64 {
+	v, n := binary.Varint(r.data)
+	if n <= 0 {
+		r.fail()
+		return 0
+	}
+	r.data = r.data[n:]
+	return v
+}
+
+func (r *drawReader) string() string {
+	n := r.uvarint()
+	if n > maxDrawStrokeIdSize || n > uint64(len(r.data)) {
+		r.fail()
+		return ""
+	}
+	s := string(r.data[:n])
+	r.data = r.data[n:]
+	return s
+}
+
+func (r *drawReader) color() string {
+	switch idx := r.byte(); {
+	case int(idx) < len(DrawPalette):
+		return DrawPalette[idx]
+	case idx == drawColorRGB:
+		if len(r.data) < 3 {
+			r.fail()
+			return ""
+		}
+		c := fmt.Sprintf("#%02X%02X%02X", r.data[0], r.data[1], r.data[2])
+		r.data = r.data[3:]
+		return c
+	default:
+		return ""
+	}
+}
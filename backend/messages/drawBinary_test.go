@@ -0,0 +1,64 @@
+package messages
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+// Coordinates and line widths are multiples of the scales so they survive quantising exactly
+var testDrawEvents = []DrawEventPayload{
+	{EventType: DrawEventStart, StrokeID: "s1", Color: "#EF120B", LineWidth: 3.5, X: 10.25, Y: 20.5},
+	{EventType: DrawEventDraw, StrokeID: "", X: 12, Y: 19.75},
+	{EventType: DrawEventDraw, X: 4.5, Y: 300},
+	{EventType: DrawEventEnd, StrokeID: "s1"},
+	{EventType: DrawEventFill, StrokeID: "f1", Color: "#123456", X: 0, Y: 0},
+	{EventType: DrawEventClear, StrokeID: "c1"},
+	{EventType: DrawEventUndo, StrokeID: "c1"},
+	{EventType: DrawEventRedo, StrokeID: "c1"},
+}
+
+func TestDrawFrameRoundTrip(t *testing.T) {
+	decoded, err := DecodeDrawFrame(EncodeDrawFrame(testDrawEvents))
+	if err != nil {
+		t.Fatalf("DecodeDrawFrame: %v", err)
+	}
+	if !reflect.DeepEqual(decoded, testDrawEvents) {
+		t.Fatalf("round trip changed the events:\ngot  %+v\nwant %+v", decoded, testDrawEvents)
+	}
+}
+
+func TestEncodeDrawFrameSkipsUnknownEvents(t *testing.T) {
+	events := []DrawEventPayload{{EventType: "scribble"}, {EventType: DrawEventClear, StrokeID: "c1"}}
+
+	decoded, err := DecodeDrawFrame(EncodeDrawFrame(events))
+	if err != nil {
+		t.Fatalf("DecodeDrawFrame: %v", err)
+	}
+	if !reflect.DeepEqual(decoded, events[1:]) {
+		t.Fatalf("got %+v, want just the clear", decoded)
+	}
+}
+
+func TestDecodeDrawFrameTruncated(t *testing.T) {
+	frame := EncodeDrawFrame(testDrawEvents)
+	for n := range len(frame) {
+		if _, err := DecodeDrawFrame(frame[:n]); !errors.Is(err, ErrInvalidDrawFrame) {
+			t.Fatalf("decoding the first %d of %d bytes gave error %v, want ErrInvalidDrawFrame", n, len(frame), err)
+		}
+	}
+}
+
+func TestDecodeDrawFrameInvalid(t *testing.T) {
+	tests := map[string][]byte{
+		"unsupported version": {2, 0},
+		"too many events":     {drawFrameVersion, 0x81, 0x40}, // 8193 events
+		"unknown event type":  {drawFrameVersion, 1, 99},
+		"long stroke ID":      append([]byte{drawFrameVersion, 1, 3, 65}, make([]byte, 65)...),
+	}
+	for name, frame := range tests {
+		if _, err := DecodeDrawFrame(frame); !errors.Is(err, ErrInvalidDrawFrame) {
+			t.Errorf("%s: got error %v, want ErrInvalidDrawFrame", name, err)
+		}
+	}
+}
@@ -11,35 +11,79 @@ const (
 	TurnEndResponse            = "turnEnd"
 	GameFinishedResponse       = "gameFinished"
 	PhaseChangeAckResponse     = "phaseChangeAck"
+	SettingsUpdateResponse     = "settingsUpdate"
+	TurnHelpResponse           = "turnHelp"
+	CanvasSnapshotResponse     = "canvasSnapshot"
+	JoinPendingResponse        = "joinPending" // Sent to a player knocking to join while they wait for the host
+	JoinRequestResponse        = "joinRequest" // Sent to the host when someone knocks to join, or gives up waiting
+	VoteUpdateResponse         = "voteUpdate"
 )
 
 type ErrorPayload struct {
 	Message string `json:"message"`
+	Code    string `json:"code,omitempty"` // Machine readable reason, for errors the client may want to react to
 }
 
+const (
+	ErrorCodeWordNotOffered    = "wordNotOffered"
+	ErrorCodeInvalidCustomWord = "invalidCustomWord"
+	ErrorCodeGameFailed        = "gameFailed" // The room hit an internal error and is stopped until the host resets it
+	ErrorCodeRoomFull          = "roomFull"
+	ErrorCodeJoinDenied        = "joinDenied"
+	ErrorCodeKicked            = "kicked"
+	ErrorCodeBanned            = "banned"
+	ErrorCodeAnswerLeak        = "answerLeak" // A chat message was blocked for giving away the word
+	ErrorCodeModerated         = "moderated"  // A chat message was blocked for containing blocked words
+	ErrorCodeInvalidName       = "invalidName"
+	ErrorCodeRateLimited       = "rateLimited"
+)
+
 type PlayerInfo struct {
 	ID                  string `json:"id"`
 	Name                string `json:"name"`
 	Score               int    `json:"score"`
 	IsHost              bool   `json:"isHost,omitempty"`
 	HasGuessedCorrectly bool   `json:"hasGuessedCorrectly,omitempty"`
+	Disconnected        bool   `json:"disconnected,omitempty"` // Connection dropped, but may still reconnect
+	IsSpectator         bool   `json:"isSpectator,omitempty"`
+	IsAfk               bool   `json:"isAfk,omitempty"`
+	WaitingToPlay       bool   `json:"waitingToPlay,omitempty"` // Spectator joining the game at the next round
 }
 
 type GameInfoPayload struct {
-	GamePhase       string       `json:"gamePhase"`
-	YourID          string       `json:"yourId"`
-	Players         []PlayerInfo `json:"players"`
-	HostID          string       `json:"hostId,omitempty"`
-	IsGameActive    bool         `json:"isGameActive"`
-	CurrentDrawerID string       `json:"currentDrawerId,omitempty"`
-	WordLength      int          `json:"wordLength,omitempty"`
-	Word            string       `json:"word,omitempty"` // For drawer on join/rejoin
-	TurnEndTime     int64        `json:"turnEndTime,omitempty"`
+	GamePhase       string              `json:"gamePhase"`
+	YourID          string              `json:"yourId"`
+	ResumeToken     string              `json:"resumeToken"` // Pass as the resumeToken query param to reconnect as this player
+	Players         []PlayerInfo        `json:"players"`
+	Spectators      []PlayerInfo        `json:"spectators"`
+	IsSpectator     bool                `json:"isSpectator,omitempty"`
+	HostID          string              `json:"hostId,omitempty"`
+	IsGameActive    bool                `json:"isGameActive"`
+	CurrentDrawerID string              `json:"currentDrawerId,omitempty"`
+	WordLength      int                 `json:"wordLength,omitempty"`
+	Mask            *WordMask           `json:"mask,omitempty"`
+	Word            string              `json:"word,omitempty"` // For drawer on join/rejoin
+	TurnEndTime     int64               `json:"turnEndTime,omitempty"`
+	RevealedLetters []RevealedLetter    `json:"revealedLetters,omitempty"` // Hints given so far, for guessers on join/rejoin
+	Settings        GameSettingsPayload `json:"settings"`
+}
+
+type GameSettingsPayload struct {
+	TotalRounds      int    `json:"totalRounds"`
+	TurnDurationSecs int    `json:"turnDurationSecs"`
+	WordChoiceSecs   int    `json:"wordChoiceSecs"`
+	TurnEndDelaySecs int    `json:"turnEndDelaySecs"`
+	WordChoiceCount  int    `json:"wordChoiceCount"`
+	AllowCustomWords bool   `json:"allowCustomWords"`
+	AfkPolicy        string `json:"afkPolicy"`   // "mark" or "remove", for players who don't respond to phase changes
+	VotePercent      int    `json:"votePercent"` // Share of voters needed to pass a vote to kick or skip
+	Moderation       string `json:"moderation"`  // "reject", "mask" or "warn", for names and chat with blocked words
 }
 
 type PlayerUpdatePayload struct {
-	Players []PlayerInfo `json:"players"`
-	HostID  string       `json:"hostId,omitempty"`
+	Players    []PlayerInfo `json:"players"`
+	Spectators []PlayerInfo `json:"spectators"`
+	HostID     string       `json:"hostId,omitempty"`
 }
 
 type TurnSetupPayload struct {
@@ -50,32 +94,98 @@ type TurnSetupPayload struct {
 }
 
 type TurnStartPayload struct {
-	CurrentDrawerID string `json:"currentDrawerId"`
-	Word            string `json:"word,omitempty"`
-	WordLength      int    `json:"wordLength"`
-	// TODO: word constants like hyphens and spaces in an array of tuples with their location
-	Players     []PlayerInfo `json:"players"`
-	TurnEndTime int64        `json:"turnEndTime"`
+	CurrentDrawerID string       `json:"currentDrawerId"`
+	Word            string       `json:"word,omitempty"`
+	WordLength      int          `json:"wordLength"`
+	Mask            WordMask     `json:"mask"`
+	Players         []PlayerInfo `json:"players"`
+	TurnEndTime     int64        `json:"turnEndTime"`
+}
+
+// WordMask is the shape of the word shown to guessers, e.g. "ice-cream van" is
+// {wordLengths: [8, 3], fixedChars: [{index: 3, char: "-"}, {index: 9, char: " "}]}
+type WordMask struct {
+	WordLengths []int       `json:"wordLengths"` // Characters to guess in each whitespace separated word
+	FixedChars  []FixedChar `json:"fixedChars"`  // Characters shown as-is, such as spaces, hyphens and punctuation
+}
+
+type FixedChar struct {
+	Index int    `json:"index"` // Character position in the word
+	Char  string `json:"char"`
 }
 
 type PhaseChangeAckPayload struct {
 	NewPhase string `json:"newPhase"`
 }
 
-// TODO: TurnHelpPayload that gives help for people that haven#t guessed the word
+// TurnHelpPayload gives help to people that haven't guessed the word yet
+type TurnHelpPayload struct {
+	RevealedLetters []RevealedLetter `json:"revealedLetters"` // Every letter revealed so far this turn
+}
+
+type RevealedLetter struct {
+	Index  int    `json:"index"` // Character position in the word
+	Letter string `json:"letter"`
+}
 
 type ChatPayload struct {
 	SenderName string `json:"senderName"`
 	Message    string `json:"message"`
 	IsSystem   bool   `json:"isSystem,omitempty"`
+	IsGuess    bool   `json:"isGuess,omitempty"` // A wrong guess rather than chat
+	Channel    string `json:"channel,omitempty"` // Empty for everyone, ChatChannelGuessed for players who know the word
 }
 
+// ChatChannelGuessed is chat only the drawer and players who have guessed the word can see
+const ChatChannelGuessed = "guessed"
+
 type TurnEndPayload struct {
 	CorrectWord string         `json:"correctWord"`
 	Players     []PlayerInfo   `json:"players"`
 	RoundScores map[string]int `json:"roundScores"`
+	Skipped     bool           `json:"skipped,omitempty"` // Ended early by a vote
+}
+
+// CanvasSnapshotPayload is everything drawn so far this turn, sent to players joining or reconnecting mid-turn
+type CanvasSnapshotPayload struct {
+	Operations []CanvasOperation `json:"operations"`          // Replayed in order, a clear wipes everything before it
+	Undone     []CanvasOperation `json:"undone,omitempty"`    // Redo stack, most recently undone last
+	Truncated  bool              `json:"truncated,omitempty"` // The drawing got too big and later operations are missing
+}
+
+type CanvasOperation struct {
+	Type      string    `json:"type"` // "stroke", "fill" or "clear"
+	StrokeID  string    `json:"strokeId"`
+	Color     string    `json:"color,omitempty"`
+	LineWidth float64   `json:"lineWidth,omitempty"`
+	Points    []float64 `json:"points,omitempty"` // Flattened x, y pairs, a fill has one point
 }
 
 type GameFinishedPayload struct {
 	Players []PlayerInfo `json:"players"`
 }
+
+type JoinRequestPayload struct {
+	PlayerID  string `json:"playerId"`
+	Name      string `json:"name"`
+	Withdrawn bool   `json:"withdrawn,omitempty"` // They left before the host answered
+}
+
+// VotePayload is the state of a vote to kick a player or skip a turn, sent whenever it changes
+type VotePayload struct {
+	Kind       string `json:"kind"` // "kick" or "skip"
+	TargetID   string `json:"targetId"`
+	TargetName string `json:"targetName"`
+	StartedBy  string `json:"startedBy"`
+	Yes        int    `json:"yes"`
+	No         int    `json:"no"`
+	Needed     int    `json:"needed"`  // Yes votes needed to pass
+	EndTime    int64  `json:"endTime"` // Unix millis, when the vote fails if not passed
+	Result     string `json:"result,omitempty"`
+}
+
+const (
+	VoteResultPassed    = "passed"
+	VoteResultFailed    = "failed"
+	VoteResultCancelled = "cancelled"
+)
@@ -0,0 +1,21 @@
+package moderation
+
+import "fmt"
+
+// Action is what a room does with a name or message that matches its blocklist
+type Action string
+
+const (
+	ActionReject Action = "reject" // Refuse it, telling the sender why
+	ActionMask   Action = "mask"   // Let it through with the blocked words starred out
+	ActionWarn   Action = "warn"   // Let it through unchanged, but warn the sender
+)
+
+func (a Action) Validate() error {
+	switch a {
+	case ActionReject, ActionMask, ActionWarn:
+		return nil
+	default:
+		return fmt.Errorf("moderation action must be %q, %q or %q", ActionReject, ActionMask, ActionWarn)
+	}
+}
@@ -0,0 +1,179 @@
+package moderation
+
+import (
+	"backend/wordmatch"
+	"bufio"
+	_ "embed"
+	"io"
+	"os"
+	"strings"
+	"unicode/utf8"
+)
+
+//go:embed blocklist.txt
+var defaultBlocklist string
+
+// Terms at least this long also match inside longer words. Shorter ones would match too many innocent words.
+const minEmbeddedTermLength = 5
+
+// leetRunes maps characters commonly swapped in for letters back to the letter
+var leetRunes = map[rune]rune{
+	'0': 'o',
+	'1': 'i',
+	'3': 'e',
+	'4': 'a',
+	'5': 's',
+	'7': 't',
+	'8': 'b',
+	'@': 'a',
+	'$': 's',
+	'!': 'i',
+	'|': 'l',
+	'+': 't',
+}
+
+// Blocklist finds blocked words in text, however they've been disguised
+type Blocklist struct {
+	terms map[string]bool // Folded forms of every term
+	long  []string        // Terms long enough to look for inside other words
+}
+
+func NewBlocklist(terms []string) *Blocklist {
+	b := &Blocklist{terms: make(map[string]bool)}
+	for _, term := range terms {
+		folded := fold(term)
+		if folded == "" || b.terms[folded] {
+			continue
+		}
+		b.terms[folded] = true
+		if utf8.RuneCountInString(folded) >= minEmbeddedTermLength {
+			b.long = append(b.long, folded)
+		}
+	}
+	return b
+}
+
+// LoadBlocklist reads a blocklist with one term per line, ignoring blank lines and # comments. An empty path
+// gives the default blocklist built into the server.
+func LoadBlocklist(path string) (*Blocklist, error) {
+	if path == "" {
+		return parseBlocklist(strings.NewReader(defaultBlocklist))
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return parseBlocklist(f)
+}
+
+func parseBlocklist(r io.Reader) (*Blocklist, error) {
+	terms := make([]string, 0)
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		terms = append(terms, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return NewBlocklist(terms), nil
+}
+
+// fold reduces text to the form terms are compared in: leetspeak undone, then normalised the same way as
+// guesses, with the spaces taken out so "f.u.c.k" and "f u c k" both become "fuck"
+func fold(s string) string {
+	s = strings.Map(func(r rune) rune {
+		if letter, ok := leetRunes[r]; ok {
+			return letter
+		}
+		return r
+	}, strings.ToLower(s))
+	return strings.ReplaceAll(wordmatch.Normalise(s), " ", "")
+}
+
+// squeeze collapses repeated letters, so "shiiiit" matches "shit"
+func squeeze(s string) string {
+	var b strings.Builder
+	var last rune
+	for i, r := range s {
+		if i == 0 || r != last {
+			b.WriteRune(r)
+		}
+		last = r
+	}
+	return b.String()
+}
+
+func (b *Blocklist) matches(folded string) bool {
+	if folded == "" {
+		return false
+	}
+	if b.terms[folded] {
+		return true
+	}
+	// Only squeeze words that were stretched out, or ordinary words would collide with terms ("as" and "ass")
+	if squeezed := squeeze(folded); squeezed != folded {
+		for term := range b.terms {
+			if squeeze(term) == squeezed {
+				return true
+			}
+		}
+	}
+	for _, term := range b.long {
+		if strings.Contains(folded, term) {
+			return true
+		}
+	}
+	return false
+}
+
+// blockedWords marks which of the whitespace separated words in text are blocked. Runs of single letters
+// are checked together too, so spelling a word out with spaces doesn't get it through.
+func (b *Blocklist) blockedWords(words []string) []bool {
+	blocked := make([]bool, len(words))
+	folded := make([]string, len(words))
+	for i, word := range words {
+		folded[i] = fold(word)
+		blocked[i] = b.matches(folded[i])
+	}
+
+	start := 0
+	for i := 0; i <= len(words); i++ {
+		if i < len(words) && utf8.RuneCountInString(folded[i]) == 1 {
+			continue
+		}
+		if i-start > 1 && b.matches(strings.Join(folded[start:i], "")) {
+			for j := start; j < i; j++ {
+				blocked[j] = true
+			}
+		}
+		start = i + 1
+	}
+	return blocked
+}
+
+// Contains reports whether text has any blocked words in it
+func (b *Blocklist) Contains(text string) bool {
+	for _, blocked := range b.blockedWords(strings.Fields(text)) {
+		if blocked {
+			return true
+		}
+	}
+	return false
+}
+
+// Mask stars out every blocked word in text, which comes back with its whitespace collapsed
+func (b *Blocklist) Mask(text string) string {
+	words := strings.Fields(text)
+	for i, blocked := range b.blockedWords(words) {
+		if blocked {
+			words[i] = strings.Repeat("*", utf8.RuneCountInString(words[i]))
+		}
+	}
+	return strings.Join(words, " ")
+}
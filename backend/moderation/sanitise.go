@@ -0,0 +1,54 @@
+package moderation
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+const MaxNameLength = 24 // In runes
+
+var ErrEmptyName = errors.New("name can't be empty")
+
+// invisibleRunes are letters that render as blank space, so can be used for names that look empty or
+// impersonate someone else
+var invisibleRunes = map[rune]bool{
+	'\u115F': true, // Hangul choseong filler
+	'\u1160': true, // Hangul jungseong filler
+	'\u3164': true, // Hangul filler
+	'\uFFA0': true, // Halfwidth hangul filler
+	'\u2800': true, // Braille pattern blank
+}
+
+// Sanitise removes characters that can hide or disguise text: control characters, zero-width and other
+// invisible formatting characters (including the bidi overrides used to make text read backwards), and
+// collapses runs of whitespace into single spaces
+func Sanitise(s string) string {
+	cleaned := strings.Map(func(r rune) rune {
+		switch {
+		case r == utf8.RuneError, invisibleRunes[r], unicode.Is(unicode.Cf, r):
+			return -1
+		case unicode.IsSpace(r):
+			return ' '
+		case unicode.IsControl(r):
+			return -1
+		default:
+			return r
+		}
+	}, s)
+	return strings.Join(strings.Fields(cleaned), " ")
+}
+
+// SanitiseName cleans up a display name, returning an error if there's nothing usable left or it's too long
+func SanitiseName(name string) (string, error) {
+	name = Sanitise(name)
+	if name == "" {
+		return "", ErrEmptyName
+	}
+	if utf8.RuneCountInString(name) > MaxNameLength {
+		return "", fmt.Errorf("name can be at most %d characters", MaxNameLength)
+	}
+	return name, nil
+}
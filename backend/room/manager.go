@@ -0,0 +1,233 @@
+package room
+
+import (
+	"backend/game"
+	"backend/words"
+	"context"
+	"errors"
+	"expvar"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+)
+
+// How often the room manager looks for idle rooms to close
+const idleCheckInterval = 15 * time.Second
+
+var ErrTooManyRooms = errors.New("too many rooms open")
+
+var (
+	metricRoomsOpen    = expvar.NewInt("rooms_open")    // Rooms currently running
+	metricRoomsCreated = expvar.NewInt("rooms_created") // Rooms created since the server started
+	metricRoomsClosed  = expvar.NewInt("rooms_closed")  // Rooms closed since the server started
+)
+
+// ManagerConfig limits how many rooms are kept and for how long
+type ManagerConfig struct {
+	MaxRooms    int           // Most rooms open at once, 0 for no limit
+	IdleTimeout time.Duration // How long a room is kept with nobody connected to it
+}
+
+func DefaultManagerConfig() ManagerConfig {
+	return ManagerConfig{
+		MaxRooms:    1000,
+		IdleTimeout: 5 * time.Minute,
+	}
+}
+
+type RoomEventType string
+
+const (
+	RoomCreated RoomEventType = "created"
+	RoomClosed  RoomEventType = "closed"
+)
+
+// RoomEvent tells listeners a room was created or closed
+type RoomEvent struct {
+	Type   RoomEventType
+	RoomId string
+	Rooms  int // Rooms open after the event
+}
+
+// Maintains the list of currently alive rooms
+type RoomManager struct {
+	rooms      map[string]*Room
+	words      words.WordSource
+	gameConfig game.Config
+	config     ManagerConfig
+	listeners  []func(RoomEvent)
+	mu         sync.Mutex
+}
+
+// RoomOptions are the choices made by whoever creates the room
+type RoomOptions struct {
+	WordPacks   []string
+	Passcode    string // Needed to join, if set
+	MaxPlayers  int    // Most players and spectators at once, 0 for no limit
+	KnockToJoin bool   // The host lets each new player in
+}
+
+const maxRoomPasscodeLength = 64
+
+func (o RoomOptions) Validate() error {
+	if len(o.Passcode) > maxRoomPasscodeLength {
+		return fmt.Errorf("passcode can be at most %d characters", maxRoomPasscodeLength)
+	}
+	if o.MaxPlayers < 0 {
+		return errors.New("max players can't be negative")
+	}
+	if o.MaxPlayers == 1 {
+		return errors.New("max players must leave room for at least 2 players")
+	}
+	return nil
+}
+
+func NewRoomManager(wordSource words.WordSource, gameConfig game.Config, config ManagerConfig) *RoomManager {
+	return &RoomManager{
+		rooms:      make(map[string]*Room),
+		words:      wordSource,
+		gameConfig: gameConfig,
+		config:     config,
+	}
+}
+
+func (rm *RoomManager) WordPacks() []words.PackInfo {
+	return rm.words.Packs()
+}
+
+func (rm *RoomManager) GetRoom(roomId string) *Room {
+	rm.mu.Lock()
+	defer rm.mu.Unlock()
+
+	room, ok := rm.rooms[roomId]
+	if !ok {
+		return nil
+	}
+	return room
+}
+
+// RoomCount is the number of rooms currently open
+func (rm *RoomManager) RoomCount() int {
+	rm.mu.Lock()
+	defer rm.mu.Unlock()
+	return len(rm.rooms)
+}
+
+// QueueDepths is the deepest outbound queue in each room (Room ID -> frames), for spotting rooms with players
+// falling behind
+func (rm *RoomManager) QueueDepths() map[string]int {
+	rm.mu.Lock()
+	rooms := make([]*Room, 0, len(rm.rooms))
+	for _, room := range rm.rooms {
+		rooms = append(rooms, room)
+	}
+	rm.mu.Unlock()
+
+	depths := make(map[string]int, len(rooms))
+	for _, room := range rooms {
+		depths[room.Id] = room.queueDepth()
+	}
+	return depths
+}
+
+// OnRoomEvent registers fn to be called whenever a room is created or closed. fn is called without any
+// locks held, but shouldn't block.
+func (rm *RoomManager) OnRoomEvent(fn func(RoomEvent)) {
+	rm.mu.Lock()
+	defer rm.mu.Unlock()
+	rm.listeners = append(rm.listeners, fn)
+}
+
+// Run closes rooms that have been empty for longer than the idle timeout, until ctx is cancelled, at which
+// point it closes every room.
+func (rm *RoomManager) Run(ctx context.Context) {
+	log.Print("starting room manager")
+
+	ticker := time.NewTicker(idleCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			log.Print("stopping room manager, closing all rooms")
+			rm.closeRooms(func(*Room) bool { return true })
+			return
+
+		case now := <-ticker.C:
+			rm.closeRooms(func(r *Room) bool { return r.idleFor(now) >= rm.config.IdleTimeout })
+		}
+	}
+}
+
+// closeRooms closes and forgets every room shouldClose picks
+func (rm *RoomManager) closeRooms(shouldClose func(*Room) bool) {
+	rm.mu.Lock()
+	closed := make([]*Room, 0)
+	for id, room := range rm.rooms {
+		if shouldClose(room) {
+			delete(rm.rooms, id)
+			closed = append(closed, room)
+		}
+	}
+	remaining := len(rm.rooms)
+	listeners := rm.listeners
+	rm.mu.Unlock()
+
+	for _, room := range closed {
+		room.Close()
+		metricRoomsOpen.Add(-1)
+		metricRoomsClosed.Add(1)
+		log.Printf("{%s} Room closed. Rooms open: %d", room.Id, remaining)
+		emit(listeners, RoomEvent{Type: RoomClosed, RoomId: room.Id, Rooms: remaining})
+	}
+}
+
+func (rm *RoomManager) CreateRoom(opts RoomOptions) (*Room, error) {
+	if err := opts.Validate(); err != nil {
+		return nil, err
+	}
+
+	packs, err := words.SelectPacks(rm.words, opts.WordPacks)
+	if err != nil {
+		return nil, err
+	}
+
+	rm.mu.Lock()
+	if rm.config.MaxRooms > 0 && len(rm.rooms) >= rm.config.MaxRooms {
+		rm.mu.Unlock()
+		log.Printf("Refusing to create a room, already at the limit of %d", rm.config.MaxRooms)
+		return nil, ErrTooManyRooms
+	}
+
+	id, err := generateUniqueSlug(func(slug string) bool {
+		_, taken := rm.rooms[slug]
+		return taken
+	})
+	if err != nil {
+		rm.mu.Unlock()
+		log.Printf("Failed to create a room: %s", err)
+		return nil, err
+	}
+
+	room := NewRoom(id, words.NewDeck(packs...), rm.gameConfig, opts)
+	rm.rooms[room.Id] = room
+	count := len(rm.rooms)
+	listeners := rm.listeners
+	rm.mu.Unlock()
+
+	go room.Run()
+	go room.Game.HandleEvents(room.ctx)
+
+	metricRoomsOpen.Add(1)
+	metricRoomsCreated.Add(1)
+	emit(listeners, RoomEvent{Type: RoomCreated, RoomId: room.Id, Rooms: count})
+
+	return room, nil
+}
+
+func emit(listeners []func(RoomEvent), event RoomEvent) {
+	for _, fn := range listeners {
+		fn(event)
+	}
+}
@@ -0,0 +1,101 @@
+package room
+
+import (
+	"backend/game"
+	"backend/words"
+	"errors"
+	"sync"
+	"testing"
+)
+
+func newTestManager(t *testing.T, config ManagerConfig) *RoomManager {
+	t.Helper()
+
+	library, err := words.NewDefaultLibrary("")
+	if err != nil {
+		t.Fatalf("loading word packs: %v", err)
+	}
+	rm := NewRoomManager(library, game.DefaultConfig(), config)
+	t.Cleanup(func() { rm.closeRooms(func(*Room) bool { return true }) })
+	return rm
+}
+
+// Run with -race: rooms are created, looked up and closed from the HTTP handlers and the manager's own
+// goroutine all at once
+func TestRoomManagerConcurrentAccess(t *testing.T) {
+	rm := newTestManager(t, ManagerConfig{})
+
+	const workers = 8
+	const roomsPerWorker = 10
+
+	var wg sync.WaitGroup
+	ids := make(chan string, workers*roomsPerWorker)
+	for range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range roomsPerWorker {
+				room, err := rm.CreateRoom(RoomOptions{})
+				if err != nil {
+					t.Errorf("CreateRoom: %v", err)
+					return
+				}
+				ids <- room.Id
+				// It may already have been closed by the other goroutines, this is just to race them
+				rm.GetRoom(room.Id)
+				rm.GetRoom("no-such-room")
+			}
+		}()
+	}
+
+	for range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range roomsPerWorker {
+				rm.closeRooms(func(r *Room) bool { return len(r.Id)%2 == i%2 })
+				rm.RoomCount()
+				rm.QueueDepths()
+			}
+		}()
+	}
+
+	wg.Wait()
+	close(ids)
+
+	rm.closeRooms(func(*Room) bool { return true })
+	if n := rm.RoomCount(); n != 0 {
+		t.Fatalf("RoomCount() = %d after closing every room, want 0", n)
+	}
+	for id := range ids {
+		if rm.GetRoom(id) != nil {
+			t.Fatalf("GetRoom(%q) found a closed room", id)
+		}
+	}
+}
+
+func TestRoomManagerMaxRooms(t *testing.T) {
+	rm := newTestManager(t, ManagerConfig{MaxRooms: 3})
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	created := 0
+	for range 10 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := rm.CreateRoom(RoomOptions{}); err == nil {
+				mu.Lock()
+				created++
+				mu.Unlock()
+			} else if !errors.Is(err, ErrTooManyRooms) {
+				t.Errorf("CreateRoom: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if created != 3 || rm.RoomCount() != 3 {
+		t.Fatalf("created %d rooms with %d open, want 3 of each", created, rm.RoomCount())
+	}
+}
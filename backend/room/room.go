@@ -3,46 +3,16 @@ package room
 import (
 	"backend/game"
 	"backend/messages"
+	"backend/words"
+	"context"
+	"crypto/subtle"
 	"log"
 	"sync"
+	"time"
 )
 
-// Maintains the list of currently alive rooms
-type RoomManager struct {
-	rooms map[string]*Room
-	mu    sync.Mutex
-}
-
-func NewRoomManager() *RoomManager {
-	return &RoomManager{
-		rooms: make(map[string]*Room),
-	}
-}
-
-func (rm *RoomManager) GetRoom(roomId string) *Room {
-	room, ok := rm.rooms[roomId]
-	if !ok {
-		return nil
-	}
-	return room
-}
-
-func (rm *RoomManager) Run() {
-	log.Print("starting room manager")
-}
-
-func (rm *RoomManager) CreateRoom() *Room {
-	rm.mu.Lock()
-	defer rm.mu.Unlock()
-
-	room := NewRoom()
-	rm.rooms[room.Id] = room
-
-	go room.Run()
-	go room.Game.HandleEvents()
-
-	return room
-}
+// How often batched draw events are sent to players using the binary draw protocol
+const drawFlushInterval = 30 * time.Millisecond
 
 // Room maintains the set of players playing the same game
 type Room struct {
@@ -51,86 +21,211 @@ type Room struct {
 	Game        *game.Game
 	Register    chan *game.Player
 	Unregister  chan *game.Player
-	PlayerReady chan *game.Player
+	playerReady chan joinRequest
 	mu          sync.Mutex
+
+	ctx        context.Context // Cancelled when the room is closed, stopping its goroutines
+	cancel     context.CancelFunc
+	emptySince time.Time // When the last connection left, if there are none
+	passcode   string
 }
 
-func NewRoom() *Room {
+func NewRoom(id string, deck *words.Deck, gameConfig game.Config, opts RoomOptions) *Room {
 	r := &Room{
-		Id:          GenerateSlug(),
+		Id:          id,
+		passcode:    opts.Passcode,
 		Players:     make(map[string]*game.Player),
 		Register:    make(chan *game.Player),
 		Unregister:  make(chan *game.Player),
-		PlayerReady: make(chan *game.Player),
+		playerReady: make(chan joinRequest),
+		emptySince:  time.Now(),
 	}
-	r.Game = game.NewGame(r)
-	log.Printf("{%s} Room created", r.Id)
+	r.ctx, r.cancel = context.WithCancel(context.Background())
+	r.Game = game.NewGame(r, deck, gameConfig, game.Admission{MaxPlayers: opts.MaxPlayers, KnockToJoin: opts.KnockToJoin})
+	log.Printf("{%s} Room created with word packs %v, private: %t, max players: %d, knock to join: %t", r.Id, deck.PackNames(), r.IsPrivate(), opts.MaxPlayers, opts.KnockToJoin)
 	return r
 }
 
+// IsPrivate reports whether a passcode is needed to join
+func (r *Room) IsPrivate() bool {
+	return r.passcode != ""
+}
+
+// CheckPasscode reports whether passcode lets someone into the room
+func (r *Room) CheckPasscode(passcode string) bool {
+	if !r.IsPrivate() {
+		return true
+	}
+	return subtle.ConstantTimeCompare([]byte(passcode), []byte(r.passcode)) == 1
+}
+
 // Run starts the Room's main loop, listening on its channels.
 func (r *Room) Run() {
 	log.Printf("{%s} Running", r.Id)
 
+	drawTicker := time.NewTicker(drawFlushInterval)
+	defer drawTicker.Stop()
+
 	for {
 		select {
+		case <-r.ctx.Done():
+			r.disconnectAll()
+			log.Printf("{%s} Stopped", r.Id)
+			return
+
+		case <-drawTicker.C:
+			r.flushDrawEvents()
+
 		case player := <-r.Register:
 			r.mu.Lock()
+			if existingPlayer, ok := r.Players[player.Id]; ok && existingPlayer != player {
+				// The same player has reconnected before their old connection noticed it was dead, so close it
+				log.Printf("{%s} Player %s '%s' reconnected, closing previous connection", r.Id, player.Id, player.Name)
+				existingPlayer.CloseSend()
+			}
 			r.Players[player.Id] = player
 			log.Printf("{%s} Player %s '%s' connection registered. Total tracked: %d", r.Id, player.Id, player.Name, len(r.Players))
 			r.mu.Unlock()
 
 		case player := <-r.Unregister:
 			r.mu.Lock()
-			var playerToRemove *game.Player
-			if existingPlayer, ok := r.Players[player.Id]; ok {
+			if existingPlayer, ok := r.Players[player.Id]; ok && existingPlayer == player {
 				delete(r.Players, player.Id)
-				close(existingPlayer.Send)
 				log.Printf("{%s} Player %s (%s) connection unregistered. Total tracked: %d", r.Id, player.Id, existingPlayer.Name, len(r.Players))
-				playerToRemove = existingPlayer
+				if len(r.Players) == 0 {
+					r.emptySince = time.Now()
+				}
 			} else {
 				log.Printf("{%s} Player %s (%s) already unregistered from Room map", r.Id, player.Id, player.Name)
 			}
+			player.CloseSend()
 			r.mu.Unlock()
 
-			if playerToRemove != nil {
-				// TODO: removing player needs to be handled better by the phases, somehow. Channel?
-				r.Game.RemovePlayer(playerToRemove)
-			}
+			// The game holds on to the player for a while in case they reconnect
+			r.Game.DisconnectPlayer(player)
 
-		case playerToAdd := <-r.PlayerReady:
-			log.Printf("{%s} Received PlayerReady signal for %s (%s). Adding to game", r.Id, playerToAdd.Id, playerToAdd.Name)
-			r.Game.AddPlayer(playerToAdd)
+		case join := <-r.playerReady:
+			log.Printf("{%s} Received PlayerReady signal for %s (%s). Adding to game", r.Id, join.player.Id, join.player.Name)
+			r.Game.AddPlayer(join.player)
+			close(join.added)
 		}
 	}
 }
 
+// joinRequest asks the room goroutine to add a player to the game, closing added once it has
+type joinRequest struct {
+	player *game.Player
+	added  chan struct{}
+}
+
+// Join hands a newly connected player to the room, returning once the game has dealt with them. The game
+// may change their name while adding them, so their pumps mustn't be started until then. It returns false
+// if the room has been closed.
+func (r *Room) Join(player *game.Player) bool {
+	player.RoomClosed = r.ctx.Done()
+
+	select {
+	case r.Register <- player:
+	case <-r.ctx.Done():
+		return false
+	}
+
+	join := joinRequest{player: player, added: make(chan struct{})}
+	select {
+	case r.playerReady <- join:
+	case <-r.ctx.Done():
+		return false
+	}
+
+	select {
+	case <-join.added:
+		return true
+	case <-r.ctx.Done():
+		return false
+	}
+}
+
+// Close shuts the room down, disconnecting anyone still in it and stopping its goroutines
+func (r *Room) Close() {
+	r.cancel()
+}
+
+// idleFor is how long the room has had nobody connected, 0 if someone is
+func (r *Room) idleFor(now time.Time) time.Duration {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if len(r.Players) > 0 {
+		return 0
+	}
+	return now.Sub(r.emptySince)
+}
+
+// queueDepth is how far behind the furthest behind player in the room is, in frames waiting to be written
+func (r *Room) queueDepth() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	deepest := 0
+	for _, p := range r.Players {
+		deepest = max(deepest, p.QueueDepth())
+	}
+	return deepest
+}
+
+// disconnectAll closes every connection still registered when the room shuts down
+func (r *Room) disconnectAll() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for id, p := range r.Players {
+		p.CloseSend()
+		delete(r.Players, id)
+	}
+}
+
+// Broadcast sends a message to every connected player the game has let in. Sends only queue the message on each player's
+// outbound queue, so messages reach each player in the order they were broadcast.
 func (r *Room) Broadcast(m messages.Message) {
 	r.mu.Lock()
 	// copy first to minimise time lock is held
-	// I'm not convinced this is needed since spawning a go routine is very fast
 	playersToSend := make([]*game.Player, 0, len(r.Players))
 	for _, player := range r.Players {
-		if player != nil {
+		if player != nil && player.Admitted() {
 			playersToSend = append(playersToSend, player)
 		}
 	}
 	r.mu.Unlock()
 
+	msg := messages.MustMarshal(m)
 	for _, p := range playersToSend {
-		go func() {
-			if p == nil {
-				return
-			}
-			p.Send <- messages.MustMarshal(m)
-		}()
+		if !p.SendBytes(msg) {
+			log.Printf("{%s} Dropped %s message for player %s (%s)", r.Id, m.Type, p.Id, p.Name)
+		}
+	}
+}
+
+func (r *Room) BroadcastDrawEvent(event messages.DrawEventPayload, players []*game.Player) {
+	msg := messages.MustMarshal(messages.Message{Type: messages.DrawEventBroadcastResponse, Payload: messages.MustMarshal(event)})
+	for _, p := range players {
+		p.SendDrawEvent(event, msg)
+	}
+}
+
+// flushDrawEvents sends each binary draw player the draw events batched up since the last tick
+func (r *Room) flushDrawEvents() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, p := range r.Players {
+		p.FlushDrawEvents()
 	}
 }
 
 func (r *Room) BroadcastToPlayers(message messages.Message, players []*game.Player) {
+	msg := messages.MustMarshal(message)
 	for _, p := range players {
-		go func() {
-			p.Send <- messages.MustMarshal(message)
-		}()
+		// Players who are disconnected but still in the game are skipped by SendBytes
+		p.SendBytes(msg)
 	}
 }
@@ -1,8 +1,9 @@
 package room
 
 import (
-	"fmt"
+	"errors"
 	"math/rand"
+	"strings"
 )
 
 var adjective = []string{
@@ -169,8 +170,36 @@ var animal = []string{
 	"zebra",
 }
 
+const (
+	slugAttempts      = 10 // Random slugs tried at each length before making them longer
+	maxSlugAdjectives = 3
+)
+
+var ErrNoFreeSlug = errors.New("couldn't find a free room id")
+
 func GenerateSlug() string {
-	adj := adjective[rand.Intn(len(adjective))]
-	animal := animal[rand.Intn(len(animal))]
-	return fmt.Sprintf("%s-%s", adj, animal)
+	return generateSlug(1)
+}
+
+// generateSlug makes an "adjective-...-animal" slug with the given number of adjectives
+func generateSlug(adjectives int) string {
+	parts := make([]string, 0, adjectives+1)
+	for range adjectives {
+		parts = append(parts, adjective[rand.Intn(len(adjective))])
+	}
+	parts = append(parts, animal[rand.Intn(len(animal))])
+	return strings.Join(parts, "-")
+}
+
+// generateUniqueSlug finds a slug that isn't taken. If the short ones keep colliding the space is getting
+// crowded, so it moves on to slugs with more adjectives.
+func generateUniqueSlug(taken func(string) bool) (string, error) {
+	for adjectives := 1; adjectives <= maxSlugAdjectives; adjectives++ {
+		for range slugAttempts {
+			if slug := generateSlug(adjectives); !taken(slug) {
+				return slug, nil
+			}
+		}
+	}
+	return "", ErrNoFreeSlug
 }
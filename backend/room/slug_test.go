@@ -0,0 +1,38 @@
+package room
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func slugAdjectives(slug string) int {
+	return strings.Count(slug, "-")
+}
+
+func TestGenerateUniqueSlugGetsLongerOnCollisions(t *testing.T) {
+	// Every slug shorter than the longest kind is taken
+	slug, err := generateUniqueSlug(func(s string) bool { return slugAdjectives(s) < maxSlugAdjectives })
+	if err != nil {
+		t.Fatalf("generateUniqueSlug: %v", err)
+	}
+	if n := slugAdjectives(slug); n != maxSlugAdjectives {
+		t.Fatalf("got %q with %d adjectives, want %d", slug, n, maxSlugAdjectives)
+	}
+}
+
+func TestGenerateUniqueSlugGivesUp(t *testing.T) {
+	tried := make(map[int]int) // Adjectives -> attempts
+	_, err := generateUniqueSlug(func(s string) bool {
+		tried[slugAdjectives(s)]++
+		return true
+	})
+	if !errors.Is(err, ErrNoFreeSlug) {
+		t.Fatalf("got error %v, want ErrNoFreeSlug", err)
+	}
+	for adjectives := 1; adjectives <= maxSlugAdjectives; adjectives++ {
+		if tried[adjectives] != slugAttempts {
+			t.Fatalf("tried %d slugs with %d adjectives, want %d", tried[adjectives], adjectives, slugAttempts)
+		}
+	}
+}
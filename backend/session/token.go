@@ -0,0 +1,63 @@
+package session
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"strings"
+)
+
+// Signer issues and checks resume tokens, which let a player reconnect to a room as the same player.
+// A token is only valid for the room it was issued in.
+type Signer struct {
+	key []byte
+}
+
+func NewSigner(key []byte) *Signer {
+	return &Signer{key: key}
+}
+
+// NewRandomSigner creates a signer with a random key, so tokens stop working when the server restarts
+func NewRandomSigner() *Signer {
+	key := make([]byte, 32)
+	_, _ = rand.Read(key)
+	return NewSigner(key)
+}
+
+// Sign returns a token of the form "<playerId>.<signature>"
+func (s *Signer) Sign(roomId, playerId string) string {
+	encodedId := base64.RawURLEncoding.EncodeToString([]byte(playerId))
+	return encodedId + "." + base64.RawURLEncoding.EncodeToString(s.mac(roomId, playerId))
+}
+
+// Verify returns the player ID the token was issued for, if it was issued by this signer for roomId
+func (s *Signer) Verify(roomId, token string) (string, bool) {
+	encodedId, encodedSig, found := strings.Cut(token, ".")
+	if !found {
+		return "", false
+	}
+
+	playerId, err := base64.RawURLEncoding.DecodeString(encodedId)
+	if err != nil || len(playerId) == 0 {
+		return "", false
+	}
+
+	sig, err := base64.RawURLEncoding.DecodeString(encodedSig)
+	if err != nil {
+		return "", false
+	}
+
+	if !hmac.Equal(sig, s.mac(roomId, string(playerId))) {
+		return "", false
+	}
+	return string(playerId), true
+}
+
+func (s *Signer) mac(roomId, playerId string) []byte {
+	h := hmac.New(sha256.New, s.key)
+	h.Write([]byte(roomId))
+	h.Write([]byte{0})
+	h.Write([]byte(playerId))
+	return h.Sum(nil)
+}
@@ -0,0 +1,68 @@
+package wordmatch
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// Answers at least this long are also looked for inside longer runs of letters. Shorter ones turn up inside
+// ordinary words too often ("art" in "start").
+const minEmbeddedLength = 5
+
+// Leaks reports whether text gives away the word or one of its aliases, whether it's written out, run
+// together, spaced out letter by letter or slightly misspelt
+func (m *Matcher) Leaks(text string) bool {
+	tokens := strings.Fields(Normalise(text))
+	if len(tokens) == 0 {
+		return false
+	}
+
+	for _, candidate := range leakCandidates(tokens, m.maxWords+1) {
+		for _, answer := range m.answers {
+			if candidate == answer || Distance(candidate, answer) <= leakDistance(answer) {
+				return true
+			}
+		}
+	}
+
+	all := compact(strings.Join(tokens, " "))
+	for _, answer := range m.answers {
+		if utf8.RuneCountInString(answer) >= minEmbeddedLength && strings.Contains(all, answer) {
+			return true
+		}
+	}
+	return false
+}
+
+// leakDistance is how many edits away from an answer still counts as giving it away. It's stricter than
+// closeDistance, as a false positive here stops someone chatting rather than just hinting they're close.
+func leakDistance(answer string) int {
+	if utf8.RuneCountInString(answer) < minEmbeddedLength {
+		return 0
+	}
+	return 1
+}
+
+// leakCandidates is every run of up to maxWords tokens run together, plus every run of single letters, which
+// is how a word spelt out with spaces or punctuation between the letters ends up once normalised
+func leakCandidates(tokens []string, maxWords int) []string {
+	candidates := make([]string, 0, len(tokens)*maxWords)
+	for i := range tokens {
+		for n := 1; n <= maxWords && i+n <= len(tokens); n++ {
+			candidates = append(candidates, strings.Join(tokens[i:i+n], ""))
+		}
+	}
+
+	var letters strings.Builder
+	for _, token := range append(tokens, "") {
+		if utf8.RuneCountInString(token) == 1 {
+			letters.WriteString(token)
+			continue
+		}
+		if utf8.RuneCountInString(letters.String()) > 1 {
+			candidates = append(candidates, letters.String())
+		}
+		letters.Reset()
+	}
+	return candidates
+}
@@ -0,0 +1,104 @@
+package wordmatch
+
+import "strings"
+
+type Result int
+
+const (
+	Incorrect Result = iota
+	Close
+	Correct
+)
+
+func (r Result) String() string {
+	switch r {
+	case Correct:
+		return "Correct"
+	case Close:
+		return "Close"
+	default:
+		return "Incorrect"
+	}
+}
+
+// Matcher checks guesses against a word and any aliases it is also accepted as
+type Matcher struct {
+	answers  []string // compacted, normalised forms of the word and its aliases
+	maxWords int      // Most words in any of the answers
+}
+
+func NewMatcher(word string, aliases []string) *Matcher {
+	m := &Matcher{}
+	for _, answer := range append([]string{word}, aliases...) {
+		normalised := Normalise(answer)
+		if compacted := compact(normalised); compacted != "" {
+			m.answers = append(m.answers, compacted)
+			m.maxWords = max(m.maxWords, len(strings.Fields(normalised)))
+		}
+	}
+	return m
+}
+
+func (m *Matcher) Check(guess string) Result {
+	normalised := compact(Normalise(guess))
+	if normalised == "" {
+		return Incorrect
+	}
+
+	result := Incorrect
+	for _, answer := range m.answers {
+		if normalised == answer {
+			return Correct
+		}
+		if Distance(normalised, answer) <= closeDistance(answer) {
+			result = Close
+		}
+	}
+	return result
+}
+
+// closeDistance is how many edits away a guess can be to count as close. Short words only allow one,
+// otherwise nearly any guess would be close.
+func closeDistance(answer string) int {
+	n := len([]rune(answer))
+	switch {
+	case n <= 3:
+		return 0
+	case n <= 6:
+		return 1
+	default:
+		return 2
+	}
+}
+
+// Distance is the edit distance between a and b counted in runes, where an insertion, deletion, substitution
+// or swap of two adjacent characters each count as one edit
+func Distance(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+
+	// Only the last two rows are needed, as a swap looks back two characters
+	prevPrev := make([]int, len(rb)+1)
+	prev := make([]int, len(rb)+1)
+	curr := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(ra); i++ {
+		curr[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+
+			if i > 1 && j > 1 && ra[i-1] == rb[j-2] && ra[i-2] == rb[j-1] {
+				curr[j] = min(curr[j], prevPrev[j-2]+1)
+			}
+		}
+		prevPrev, prev, curr = prev, curr, prevPrev
+	}
+
+	return prev[len(rb)]
+}
@@ -0,0 +1,81 @@
+package wordmatch
+
+import (
+	"strings"
+	"unicode"
+)
+
+// foldedRunes maps precomposed characters to their unaccented form. Decomposed input (a base letter followed
+// by combining marks) is handled separately by dropping the marks.
+var foldedRunes = buildFoldTable(map[string]string{
+	"àáâãäåāăą":  "a",
+	"çćĉċč":      "c",
+	"ďđð":        "d",
+	"èéêëēĕėęě":  "e",
+	"ĝğġģ":       "g",
+	"ĥħ":         "h",
+	"ìíîïĩīĭįı":  "i",
+	"ĵ":          "j",
+	"ķ":          "k",
+	"ĺļľŀł":      "l",
+	"ñńņňŉ":      "n",
+	"òóôõöøōŏő":  "o",
+	"ŕŗř":        "r",
+	"śŝşš":       "s",
+	"ţťŧ":        "t",
+	"ùúûüũūŭůűų": "u",
+	"ŵ":          "w",
+	"ýÿŷ":        "y",
+	"źżž":        "z",
+	"æ":          "ae",
+	"œ":          "oe",
+	"ß":          "ss",
+	"þ":          "th",
+})
+
+func buildFoldTable(groups map[string]string) map[rune]string {
+	table := make(map[rune]string)
+	for runes, folded := range groups {
+		for _, r := range runes {
+			table[r] = folded
+		}
+	}
+	return table
+}
+
+// Normalise puts text into a canonical form for comparing guesses: lower case, accents stripped,
+// punctuation treated as spaces and whitespace collapsed, so "  Crème-Brûlée! " becomes "creme brulee".
+func Normalise(s string) string {
+	var b strings.Builder
+	b.Grow(len(s))
+
+	pendingSpace := false
+	for _, r := range strings.ToLower(s) {
+		if unicode.Is(unicode.Mn, r) {
+			continue
+		}
+
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			pendingSpace = b.Len() > 0
+			continue
+		}
+
+		if pendingSpace {
+			b.WriteByte(' ')
+			pendingSpace = false
+		}
+
+		if folded, ok := foldedRunes[r]; ok {
+			b.WriteString(folded)
+		} else {
+			b.WriteRune(r)
+		}
+	}
+
+	return b.String()
+}
+
+// compact drops the spaces from normalised text so "ice cream" and "icecream" compare equal
+func compact(normalised string) string {
+	return strings.ReplaceAll(normalised, " ", "")
+}
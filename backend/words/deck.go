@@ -0,0 +1,76 @@
+package words
+
+import (
+	"log"
+	"math/rand"
+	"strings"
+)
+
+// Deck deals words from a set of packs without repeating any word until every word has been dealt
+type Deck struct {
+	packNames []string
+	words     []string
+	aliases   map[string][]string // lower cased word -> aliases
+	next      int
+}
+
+func NewDeck(packs ...*Pack) *Deck {
+	d := &Deck{aliases: make(map[string][]string)}
+
+	seen := make(map[string]bool)
+	for _, pack := range packs {
+		d.packNames = append(d.packNames, pack.Name)
+		for _, w := range pack.Words {
+			key := strings.ToLower(w)
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+			d.words = append(d.words, w)
+		}
+
+		for w, aliases := range pack.Aliases {
+			key := strings.ToLower(w)
+			d.aliases[key] = append(d.aliases[key], aliases...)
+		}
+	}
+
+	d.Reset()
+	return d
+}
+
+// PackNames returns the names of the packs this deck was built from
+func (d *Deck) PackNames() []string {
+	return d.packNames
+}
+
+// Aliases returns the other answers accepted for word
+func (d *Deck) Aliases(word string) []string {
+	return d.aliases[strings.ToLower(word)]
+}
+
+// Reset puts every word back in the deck and shuffles it, e.g. for a new game
+func (d *Deck) Reset() {
+	rand.Shuffle(len(d.words), func(i, j int) {
+		d.words[i], d.words[j] = d.words[j], d.words[i]
+	})
+	d.next = 0
+}
+
+// Deal returns n distinct words that haven't been dealt since the last Reset. If the deck runs out it is
+// reshuffled, so words only repeat once the whole deck has been used.
+func (d *Deck) Deal(n int) []string {
+	if n > len(d.words) {
+		n = len(d.words)
+	}
+
+	if d.next+n > len(d.words) {
+		log.Printf("Word deck (%s) exhausted, reshuffling", strings.Join(d.packNames, ", "))
+		d.Reset()
+	}
+
+	dealt := make([]string, n)
+	copy(dealt, d.words[d.next:d.next+n])
+	d.next += n
+	return dealt
+}
@@ -0,0 +1,124 @@
+package words
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// Pack is a named collection of words that a room can draw from
+type Pack struct {
+	Name       string   `json:"name"`
+	Language   string   `json:"language,omitempty"`
+	Difficulty string   `json:"difficulty,omitempty"`
+	Category   string   `json:"category,omitempty"`
+	Words      []string `json:"words"`
+
+	// Aliases are other answers accepted for a word, e.g. "ice cream": ["icecream"]
+	Aliases map[string][]string `json:"aliases,omitempty"`
+}
+
+// PackInfo describes a pack without its words, used when listing what's available
+type PackInfo struct {
+	Name       string `json:"name"`
+	Language   string `json:"language,omitempty"`
+	Difficulty string `json:"difficulty,omitempty"`
+	Category   string `json:"category,omitempty"`
+	WordCount  int    `json:"wordCount"`
+}
+
+func (p *Pack) Info() PackInfo {
+	return PackInfo{
+		Name:       p.Name,
+		Language:   p.Language,
+		Difficulty: p.Difficulty,
+		Category:   p.Category,
+		WordCount:  len(p.Words),
+	}
+}
+
+// parseJSONPack reads a pack in the form {"name": ..., "language": ..., "words": [...]}.
+// If the file doesn't name itself, fallbackName is used.
+func parseJSONPack(fallbackName string, data []byte) (*Pack, error) {
+	var pack Pack
+	if err := json.Unmarshal(data, &pack); err != nil {
+		return nil, fmt.Errorf("invalid json word pack %s: %w", fallbackName, err)
+	}
+	if pack.Name == "" {
+		pack.Name = fallbackName
+	}
+	pack.Words = cleanWords(pack.Words)
+	return &pack, nil
+}
+
+// parseTextPack reads a pack with one word per line, optionally followed by aliases as "word | alias | alias".
+// Lines starting with '#' are comments, and comments of the form "# key: value" set the pack metadata.
+func parseTextPack(fallbackName string, data []byte) (*Pack, error) {
+	pack := Pack{Name: fallbackName}
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+
+		if comment, ok := strings.CutPrefix(line, "#"); ok {
+			key, value, found := strings.Cut(comment, ":")
+			if !found {
+				continue
+			}
+			value = strings.TrimSpace(value)
+			switch strings.ToLower(strings.TrimSpace(key)) {
+			case "name":
+				pack.Name = value
+			case "language":
+				pack.Language = value
+			case "difficulty":
+				pack.Difficulty = value
+			case "category":
+				pack.Category = value
+			}
+			continue
+		}
+
+		word, aliases, hasAliases := strings.Cut(line, "|")
+		word = strings.TrimSpace(word)
+		pack.Words = append(pack.Words, word)
+
+		if hasAliases {
+			if pack.Aliases == nil {
+				pack.Aliases = make(map[string][]string)
+			}
+			for _, alias := range strings.Split(aliases, "|") {
+				if alias = strings.TrimSpace(alias); alias != "" {
+					pack.Aliases[word] = append(pack.Aliases[word], alias)
+				}
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed reading text word pack %s: %w", fallbackName, err)
+	}
+
+	pack.Words = cleanWords(pack.Words)
+	return &pack, nil
+}
+
+// cleanWords trims whitespace and drops empty and duplicate entries, keeping the first spelling seen
+func cleanWords(words []string) []string {
+	seen := make(map[string]bool, len(words))
+	cleaned := make([]string, 0, len(words))
+	for _, w := range words {
+		w = strings.TrimSpace(w)
+		key := strings.ToLower(w)
+		if w == "" || seen[key] {
+			continue
+		}
+		seen[key] = true
+		cleaned = append(cleaned, w)
+	}
+	return cleaned
+}
@@ -0,0 +1,157 @@
+package words
+
+import (
+	"embed"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path"
+	"slices"
+	"strings"
+	"sync"
+)
+
+const DefaultPackName = "default"
+
+//go:embed packs/*
+var embeddedPacks embed.FS
+
+// WordSource provides named word packs for rooms to pick from
+type WordSource interface {
+	Pack(name string) (*Pack, bool)
+	Packs() []PackInfo
+}
+
+// Library is a WordSource backed by packs loaded from files
+type Library struct {
+	packs map[string]*Pack
+	mu    sync.RWMutex
+}
+
+func NewLibrary() *Library {
+	return &Library{
+		packs: make(map[string]*Pack),
+	}
+}
+
+// NewDefaultLibrary loads the packs embedded in the binary, plus any packs found in dir if it isn't empty.
+// Packs in dir replace embedded packs with the same name.
+func NewDefaultLibrary(dir string) (*Library, error) {
+	l := NewLibrary()
+
+	packsFS, err := fs.Sub(embeddedPacks, "packs")
+	if err != nil {
+		return nil, err
+	}
+	if err := l.LoadFS(packsFS); err != nil {
+		return nil, fmt.Errorf("failed to load embedded word packs: %w", err)
+	}
+
+	if dir != "" {
+		if err := l.LoadFS(os.DirFS(dir)); err != nil {
+			return nil, fmt.Errorf("failed to load word packs from %s: %w", dir, err)
+		}
+	}
+
+	return l, nil
+}
+
+// LoadFS adds every .json and .txt pack at the top level of fsys
+func (l *Library) LoadFS(fsys fs.FS) error {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return err
+	}
+
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+
+		fileName := entry.Name()
+		ext := path.Ext(fileName)
+		if ext != ".json" && ext != ".txt" {
+			continue
+		}
+
+		data, err := fs.ReadFile(fsys, fileName)
+		if err != nil {
+			return err
+		}
+
+		name := strings.TrimSuffix(fileName, ext)
+		var pack *Pack
+		if ext == ".json" {
+			pack, err = parseJSONPack(name, data)
+		} else {
+			pack, err = parseTextPack(name, data)
+		}
+		if err != nil {
+			return err
+		}
+
+		if err := l.Add(pack); err != nil {
+			return fmt.Errorf("%s: %w", fileName, err)
+		}
+	}
+
+	return nil
+}
+
+func (l *Library) Add(pack *Pack) error {
+	if pack.Name == "" {
+		return fmt.Errorf("word pack has no name")
+	}
+	if len(pack.Words) == 0 {
+		return fmt.Errorf("word pack %s has no words", pack.Name)
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if _, exists := l.packs[pack.Name]; exists {
+		log.Printf("Word pack %s replaced", pack.Name)
+	}
+	l.packs[pack.Name] = pack
+	return nil
+}
+
+func (l *Library) Pack(name string) (*Pack, bool) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	pack, ok := l.packs[name]
+	return pack, ok
+}
+
+func (l *Library) Packs() []PackInfo {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	infos := make([]PackInfo, 0, len(l.packs))
+	for _, pack := range l.packs {
+		infos = append(infos, pack.Info())
+	}
+	slices.SortFunc(infos, func(a, b PackInfo) int { return strings.Compare(a.Name, b.Name) })
+	return infos
+}
+
+// SelectPacks looks up each named pack, falling back to the default pack when no names are given
+func SelectPacks(src WordSource, names []string) ([]*Pack, error) {
+	if len(names) == 0 {
+		names = []string{DefaultPackName}
+	}
+
+	packs := make([]*Pack, 0, len(names))
+	for _, name := range names {
+		pack, ok := src.Pack(name)
+		if !ok {
+			return nil, fmt.Errorf("unknown word pack %q", name)
+		}
+		if !slices.Contains(packs, pack) {
+			packs = append(packs, pack)
+		}
+	}
+	return packs, nil
+}
@@ -20,6 +20,9 @@ export default defineConfig(({ mode }) => {
                 '/create-room': {
                     target: 'http://localhost:8080'
                 },
+                '/word-packs': {
+                    target: 'http://localhost:8080'
+                },
                 '/api': {
                     target: 'http://localhost:8080'
                 }